@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"runtime"
 	"strings"
 
+	"hmerritt/go-ics-to-markdown/parse"
+	"hmerritt/go-ics-to-markdown/render"
 	"hmerritt/go-ics-to-markdown/ui"
 
 	"github.com/jessevdk/go-flags"
@@ -13,7 +16,7 @@ import (
 )
 
 // Slice of all flag names
-var FlagNames = []string{flagStrict.Name, flagForce.Name}
+var FlagNames = []string{flagStrict.Name, flagForce.Name, flagHorizon.Name, flagTimezone.Name, flagSplitDays.Name, flagFormat.Name, flagTemplate.Name, flagColumns.Name, flagDateFormat.Name, flagTimeFormat.Name, flagLocale.Name, flagOutput.Name, flagShowCalendar.Name, flagDedupe.Name, flagDir.Name, flagRecursive.Name, flagJobs.Name, flagWatch.Name, flagEvery.Name, flagNoCache.Name, flagCacheTTL.Name, flagOffline.Name, flagHeader.Name, flagUser.Name, flagPasswordEnv.Name, flagTokenEnv.Name, flagAuthHost.Name, flagNetrc.Name, flagTimeout.Name, flagRetries.Name, flagMaxSize.Name, flagMaxRedirects.Name, flagMatch.Name, flagRange.Name, flagNow.Name, flagGrep.Name, flagExclude.Name, flagWhere.Name, flagFilter.Name, flagSort.Name}
 
 // Slice of global flag names
 var FlagNamesGlobal = []string{flagStrict.Name, flagForce.Name}
@@ -64,10 +67,48 @@ func (fm *FlagMap) Help() string {
 func (fm *FlagMap) Parse(UI *ui.Ui, args []string) []string {
 	// Struct used to parse flags
 	var opts struct {
-		Strict bool   `short:"s" long:"strict"`
-		Force  bool   `short:"f" long:"force"`
-		Start  string `short:"S" long:"start"`
-		End    string `short:"E" long:"end"`
+		Strict       bool     `short:"s" long:"strict"`
+		Force        bool     `short:"f" long:"force"`
+		Start        string   `short:"S" long:"start"`
+		End          string   `short:"E" long:"end"`
+		Horizon      string   `long:"horizon"`
+		Timezone     string   `short:"z" long:"timezone"`
+		SplitDays    bool     `long:"split-days"`
+		Format       string   `short:"F" long:"format"`
+		Template     string   `short:"t" long:"template"`
+		Columns      string   `short:"c" long:"columns"`
+		DateFormat   string   `long:"date-format"`
+		TimeFormat   string   `long:"time-format"`
+		Locale       string   `short:"l" long:"locale"`
+		Output       string   `short:"o" long:"output"`
+		ShowCalendar bool     `long:"show-calendar"`
+		Dedupe       string   `long:"dedupe"`
+		Dir          string   `short:"d" long:"dir"`
+		Recursive    bool     `short:"r" long:"recursive"`
+		Jobs         int      `short:"j" long:"jobs"`
+		Watch        bool     `short:"w" long:"watch"`
+		Every        string   `long:"every"`
+		NoCache      bool     `long:"no-cache"`
+		CacheTTL     string   `long:"cache-ttl"`
+		Offline      bool     `long:"offline"`
+		Header       []string `short:"H" long:"header"`
+		User         string   `short:"u" long:"user"`
+		PasswordEnv  string   `long:"password-env"`
+		TokenEnv     string   `long:"token-env"`
+		AuthHost     string   `long:"auth-host"`
+		Netrc        string   `long:"netrc"`
+		Timeout      string   `long:"timeout"`
+		Retries      string   `long:"retries"`
+		MaxSize      string   `long:"max-size"`
+		MaxRedirects string   `long:"max-redirects"`
+		Match        string   `long:"match"`
+		Range        string   `long:"range"`
+		Now          string   `long:"now"`
+		Grep         []string `long:"grep"`
+		Exclude      []string `long:"exclude"`
+		Where        []string `long:"where"`
+		Filter       string   `long:"filter" unquote:"false"`
+		Sort         string   `long:"sort"`
 	}
 
 	// Parse flags from `args'.
@@ -93,6 +134,44 @@ func (fm *FlagMap) Parse(UI *ui.Ui, args []string) []string {
 	updateFmWithOps("force", opts.Force)
 	updateFmWithOps("start", opts.Start)
 	updateFmWithOps("end", opts.End)
+	updateFmWithOps("horizon", opts.Horizon)
+	updateFmWithOps("timezone", opts.Timezone)
+	updateFmWithOps("split-days", opts.SplitDays)
+	updateFmWithOps("format", opts.Format)
+	updateFmWithOps("template", opts.Template)
+	updateFmWithOps("columns", opts.Columns)
+	updateFmWithOps("date-format", opts.DateFormat)
+	updateFmWithOps("time-format", opts.TimeFormat)
+	updateFmWithOps("locale", opts.Locale)
+	updateFmWithOps("output", opts.Output)
+	updateFmWithOps("show-calendar", opts.ShowCalendar)
+	updateFmWithOps("dedupe", opts.Dedupe)
+	updateFmWithOps("dir", opts.Dir)
+	updateFmWithOps("recursive", opts.Recursive)
+	updateFmWithOps("jobs", opts.Jobs)
+	updateFmWithOps("watch", opts.Watch)
+	updateFmWithOps("every", opts.Every)
+	updateFmWithOps("no-cache", opts.NoCache)
+	updateFmWithOps("cache-ttl", opts.CacheTTL)
+	updateFmWithOps("offline", opts.Offline)
+	updateFmWithOps("header", opts.Header)
+	updateFmWithOps("user", opts.User)
+	updateFmWithOps("password-env", opts.PasswordEnv)
+	updateFmWithOps("token-env", opts.TokenEnv)
+	updateFmWithOps("auth-host", opts.AuthHost)
+	updateFmWithOps("netrc", opts.Netrc)
+	updateFmWithOps("timeout", opts.Timeout)
+	updateFmWithOps("retries", opts.Retries)
+	updateFmWithOps("max-size", opts.MaxSize)
+	updateFmWithOps("max-redirects", opts.MaxRedirects)
+	updateFmWithOps("match", opts.Match)
+	updateFmWithOps("range", opts.Range)
+	updateFmWithOps("now", opts.Now)
+	updateFmWithOps("grep", opts.Grep)
+	updateFmWithOps("exclude", opts.Exclude)
+	updateFmWithOps("where", opts.Where)
+	updateFmWithOps("filter", opts.Filter)
+	updateFmWithOps("sort", opts.Sort)
 
 	return args
 }
@@ -124,7 +203,7 @@ var flagForce = Flag{
 // Start date
 var flagStart = Flag{
 	Name:    "start",
-	Usage:   "Start date used to filter events.",
+	Usage:   "Start date used to filter events, e.g. '2024-09-01', an RFC 3339 timestamp, 'today' or '2w ago' (or '--start=-2w').",
 	Default: nil,
 	Value:   nil,
 }
@@ -134,7 +213,390 @@ var flagStart = Flag{
 // End date
 var flagEnd = Flag{
 	Name:    "end",
-	Usage:   "End date used to filter events.",
+	Usage:   "End date used to filter events (not included), e.g. '2024-09-30', an RFC 3339 timestamp, 'tomorrow' or '+7d'.",
 	Default: nil,
 	Value:   nil,
 }
+
+// flag --horizon
+//
+// Expansion limit for recurring events
+var flagHorizon = Flag{
+	Name:    "horizon",
+	Usage:   "How far recurring events are expanded when no end (or start) date is given, ahead of and back from now (e.g. '90d', '52w').",
+	Default: "365d",
+	Value:   nil,
+}
+
+// flag --timezone
+//
+// Output timezone
+var flagTimezone = Flag{
+	Name:    "timezone",
+	Usage:   "Timezone events are converted to, e.g. 'Europe/London' (defaults to the calendar timezone).",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --split-days
+//
+// Split multi-day events into one row per day
+var flagSplitDays = Flag{
+	Name:    "split-days",
+	Usage:   "Split events spanning multiple days into one row per day.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --format
+//
+// Output format (registered renderer name)
+var flagFormat = Flag{
+	Name:    "format",
+	Usage:   "Output format: " + strings.Join(render.Names(), ", ") + ".",
+	Default: render.DefaultFormat,
+	Value:   nil,
+}
+
+// flag --template
+//
+// User-supplied text/template file
+var flagTemplate = Flag{
+	Name:    "template",
+	Usage:   "Render events through a Go text/template file instead of a built-in format.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --columns
+//
+// Table columns, in order, optionally renamed
+var flagColumns = Flag{
+	Name:    "columns",
+	Usage:   "Table columns in order, renamed with a colon, e.g. 'summary:What,date,time' (available: " + strings.Join(render.ColumnKeys(), ", ") + "). Defaults to the " + columnsEnv + " environment variable.",
+	Default: nil,
+	Value:   nil,
+}
+
+// Environment variable with the default table columns, as for '--columns'
+const columnsEnv = "ICS_TO_MARKDOWN_COLUMNS"
+
+// flag --date-format
+//
+// Date layout, Go or strftime-style
+var flagDateFormat = Flag{
+	Name:    "date-format",
+	Usage:   "Date format as a Go layout or strftime-style, e.g. '02.01.2006' or '%d.%m.%Y' (defaults to the locale format).",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --time-format
+//
+// Time layout, Go or strftime-style
+var flagTimeFormat = Flag{
+	Name:    "time-format",
+	Usage:   "Time format as a Go layout or strftime-style, e.g. '3:04 PM' or '%I:%M %p' (defaults to the locale format).",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --locale
+//
+// Language of weekday/month names and column headers
+var flagLocale = Flag{
+	Name:    "locale",
+	Usage:   "Locale for weekday and month names and column headers: " + strings.Join(render.LocaleNames(), ", ") + ".",
+	Default: render.DefaultLocale,
+	Value:   nil,
+}
+
+// flag --output
+//
+// Markdown output path, '-' for stdout
+var flagOutput = Flag{
+	Name:    "output",
+	Usage:   "Path to write the markdown to, or '-' for stdout (defaults to '<file>.md' in the current directory).",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --show-calendar
+//
+// Add a column with each event's calendar
+var flagShowCalendar = Flag{
+	Name:    "show-calendar",
+	Usage:   "Add a 'Calendar' column with the name of the calendar each event is from (X-WR-CALNAME, or 'name=FILE').",
+	Default: false,
+	Value:   false,
+}
+
+// flag --dedupe
+//
+// How duplicate events are removed
+var flagDedupe = Flag{
+	Name:    "dedupe",
+	Usage:   "Remove duplicate events: 'off', 'uid' (same UID and RECURRENCE-ID) or 'fuzzy' (also same summary, start and end). The latest SEQUENCE/LAST-MODIFIED is kept.",
+	Default: string(parse.DefaultDedupeMode),
+	Value:   nil,
+}
+
+// flag --dir
+//
+// Convert every ICS file in a directory
+var flagDir = Flag{
+	Name:    "dir",
+	Usage:   "Convert every ICS file in a directory, into the same tree under '--output'.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --recursive
+//
+// Include subdirectories with --dir
+var flagRecursive = Flag{
+	Name:    "recursive",
+	Usage:   "Include ICS files in subdirectories when using '--dir'.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --jobs
+//
+// Number of files converted at once with --dir
+var flagJobs = Flag{
+	Name:    "jobs",
+	Usage:   "Number of files to convert at once when using '--dir' (defaults to the number of CPUs).",
+	Default: runtime.NumCPU(),
+	Value:   nil,
+}
+
+// flag --watch
+//
+// Regenerate markdown when input files change
+var flagWatch = Flag{
+	Name:    "watch",
+	Usage:   "Keep running, and regenerate the markdown whenever an input file (or a file in '--dir') changes.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --every
+//
+// Re-fetch sources on an interval
+var flagEvery = Flag{
+	Name:    "every",
+	Usage:   "Keep running, fetching the sources again on this interval (e.g. '15m') and regenerating the markdown if they changed.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --no-cache
+//
+// Always download URLs, without the cache
+var flagNoCache = Flag{
+	Name:    "no-cache",
+	Usage:   "Do not read or write the cache of downloaded calendars.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --cache-ttl
+//
+// How long cached URLs are used without revalidating
+var flagCacheTTL = Flag{
+	Name:    "cache-ttl",
+	Usage:   "How long a cached calendar is used before checking the URL for changes, e.g. '1h' or '0' to always check.",
+	Default: parse.DefaultCacheTTL.String(),
+	Value:   nil,
+}
+
+// flag --offline
+//
+// Only use cached URLs
+var flagOffline = Flag{
+	Name:    "offline",
+	Usage:   "Use the cached copy of URLs without any network requests.",
+	Default: false,
+	Value:   false,
+}
+
+// flag --header
+//
+// Extra request header for URLs, can be repeated
+var flagHeader = Flag{
+	Name:    "header",
+	Usage:   "Extra header sent when fetching URLs, e.g. 'Authorization: Bearer ...'. Can be used multiple times.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --user
+//
+// Basic auth username
+var flagUser = Flag{
+	Name:    "user",
+	Usage:   "Username for basic auth when fetching URLs, with the password from '--password-env'.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --password-env
+//
+// Environment variable with the basic auth password
+var flagPasswordEnv = Flag{
+	Name:    "password-env",
+	Usage:   "Name of the environment variable holding the basic auth password.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --token-env
+//
+// Environment variable with a bearer token
+var flagTokenEnv = Flag{
+	Name:    "token-env",
+	Usage:   "Name of the environment variable holding a bearer token for fetching URLs.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --auth-host
+//
+// Host the header, basic auth and bearer token flags are sent to
+var flagAuthHost = Flag{
+	Name:    "auth-host",
+	Usage:   "Host that '--header', '--user' and '--token-env' are sent to, e.g. 'example.com' or 'example.com:8443'. Defaults to the host of the URLs, and is needed when they are on different hosts.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --netrc
+//
+// Netrc-style file with credentials per host
+var flagNetrc = Flag{
+	Name:    "netrc",
+	Usage:   "Netrc-style file with credentials per host ('machine <host> login <user> password <secret>', or only a password for a bearer token).",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --timeout
+//
+// Time limit of each request
+var flagTimeout = Flag{
+	Name:    "timeout",
+	Usage:   "Time limit for each request when fetching URLs.",
+	Default: parse.DefaultFetchTimeout.String(),
+	Value:   nil,
+}
+
+// flag --retries
+//
+// Retries on timeouts, dropped connections and 5xx responses
+var flagRetries = Flag{
+	Name:    "retries",
+	Usage:   "Times to retry fetching a URL after a timeout, dropped connection or server error (5xx), waiting longer each time.",
+	Default: parse.DefaultFetchAttempts - 1,
+	Value:   nil,
+}
+
+// flag --max-size
+//
+// Largest response body accepted
+var flagMaxSize = Flag{
+	Name:    "max-size",
+	Usage:   "Largest calendar accepted when fetching URLs, e.g. '500KB' or '100MB'.",
+	Default: parse.FormatSize(parse.DefaultMaxSize),
+	Value:   nil,
+}
+
+// flag --max-redirects
+//
+// Redirects followed before giving up
+var flagMaxRedirects = Flag{
+	Name:    "max-redirects",
+	Usage:   "Number of redirects followed when fetching URLs.",
+	Default: parse.DefaultMaxRedirects,
+	Value:   nil,
+}
+
+// flag --match
+//
+// How events are matched against --start/--end
+var flagMatch = Flag{
+	Name:    "match",
+	Usage:   "How events are matched against '--start' and '--end': 'contained' (entirely within), 'overlap' (any part within) or 'starts-within'.",
+	Default: string(parse.DefaultMatchMode),
+	Value:   nil,
+}
+
+// flag --range
+//
+// Period used to filter events
+var flagRange = Flag{
+	Name:    "range",
+	Usage:   "Period used to filter events, instead of '--start' and '--end', e.g. 'today', 'this-week', 'next-month', 'next monday', '2024-Q3', '2024-W33' or '2w ago' (or '--range=-2w').",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --now
+//
+// Reference time of relative dates
+var flagNow = Flag{
+	Name:    "now",
+	Usage:   "Time relative dates ('today', '+7d', 'this-week') are resolved against, instead of the current time.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --grep
+//
+// Only keep events containing text, can be repeated
+var flagGrep = Flag{
+	Name:    "grep",
+	Usage:   "Only keep events containing the text (ignoring case), or matching a '/regex/', in their summary, description, location, categories, organizer or attendees. Can be used multiple times, events match any of them.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --exclude
+//
+// Remove events containing text, can be repeated
+var flagExclude = Flag{
+	Name:    "exclude",
+	Usage:   "Remove events containing the text (ignoring case), or matching a '/regex/', in the same fields as '--grep'. Can be used multiple times.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --where
+//
+// Filter events by a field, can be repeated
+var flagWhere = Flag{
+	Name:    "where",
+	Usage:   "Filter events by a field: 'field=text' (contains, ignoring case), 'field~regex', 'field!=text' or 'field!~regex', e.g. \"location~Room 4\". Can be used multiple times, events match all of them.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --filter
+//
+// Expression events must match
+var flagFilter = Flag{
+	Name:    "filter",
+	Usage:   "Only keep events matching an expression, e.g. 'status != \"CANCELLED\" && duration > 30m && \"ops\" in categories'.",
+	Default: nil,
+	Value:   nil,
+}
+
+// flag --sort
+//
+// Order of the events
+var flagSort = Flag{
+	Name:    "sort",
+	Usage:   "Order of the events, by comma separated fields, sorted descending with ':desc' (e.g. 'start:desc') or a '-' prefix (e.g. '--sort=-start'): " + strings.Join(parse.SortFields(), ", ") + ". Events which tie keep a fixed order.",
+	Default: "start",
+	Value:   nil,
+}
@@ -35,6 +35,44 @@ func GetFlagMap(which []string) *FlagMap {
 	addToMap(&flagForce)
 	addToMap(&flagStart)
 	addToMap(&flagEnd)
+	addToMap(&flagHorizon)
+	addToMap(&flagTimezone)
+	addToMap(&flagSplitDays)
+	addToMap(&flagFormat)
+	addToMap(&flagTemplate)
+	addToMap(&flagColumns)
+	addToMap(&flagDateFormat)
+	addToMap(&flagTimeFormat)
+	addToMap(&flagLocale)
+	addToMap(&flagOutput)
+	addToMap(&flagShowCalendar)
+	addToMap(&flagDedupe)
+	addToMap(&flagDir)
+	addToMap(&flagRecursive)
+	addToMap(&flagJobs)
+	addToMap(&flagWatch)
+	addToMap(&flagEvery)
+	addToMap(&flagNoCache)
+	addToMap(&flagCacheTTL)
+	addToMap(&flagOffline)
+	addToMap(&flagHeader)
+	addToMap(&flagUser)
+	addToMap(&flagPasswordEnv)
+	addToMap(&flagTokenEnv)
+	addToMap(&flagAuthHost)
+	addToMap(&flagNetrc)
+	addToMap(&flagTimeout)
+	addToMap(&flagRetries)
+	addToMap(&flagMaxSize)
+	addToMap(&flagMaxRedirects)
+	addToMap(&flagMatch)
+	addToMap(&flagRange)
+	addToMap(&flagNow)
+	addToMap(&flagGrep)
+	addToMap(&flagExclude)
+	addToMap(&flagWhere)
+	addToMap(&flagFilter)
+	addToMap(&flagSort)
 
 	return &fm
 }
@@ -74,6 +74,8 @@ func (c *ListCommand) Run(args []string) int {
 
 	c.UI.Output("\nConvert ics->markdown file using:")
 	c.UI.Output("$ ics-to-markdown run <FILE>")
+	c.UI.Output("\nOr convert them all using:")
+	c.UI.Output("$ ics-to-markdown run --dir " + path)
 
 	return 0
 }
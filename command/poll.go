@@ -0,0 +1,50 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Fetch sources every 'interval' and regenerate the markdown when they
+// change. URLs are fetched with conditional requests (ETag/Last-Modified).
+//
+// Runs until interrupted.
+func (c *RunCommand) poll(interval time.Duration, sources []icsSource, mdPath string, toStdout bool, fetchOptions parse.FetchOptions, conv *conversion) int {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	return c.pollUntil(interrupt, interval, sources, mdPath, toStdout, fetchOptions, conv)
+}
+
+// Polling loop of 'poll', which stops once 'stop' receives
+func (c *RunCommand) pollUntil(stop <-chan os.Signal, interval time.Duration, sources []icsSource, mdPath string, toStdout bool, fetchOptions parse.FetchOptions, conv *conversion) int {
+	poller := parse.NewPoller(sourcePaths(sources))
+	poller.Options = fetchOptions
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		timeStart := time.Now()
+		fetched, changed := poller.Poll()
+
+		if changed {
+			c.convertFetched(sources, fetched, mdPath, toStdout, conv, timeStart)
+		} else {
+			c.UI.Info(fmt.Sprintf("[%s] No changes, skipping.", time.Now().Format("15:04:05")))
+		}
+		c.UI.Info("\nNext fetch at " + time.Now().Add(interval).Format("15:04:05") + ", press Ctrl+C to stop.\n")
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			c.UI.Output("Stopped polling.")
+			return 0
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"bytes"
+	"hmerritt/go-ics-to-markdown/parse"
+	"hmerritt/go-ics-to-markdown/render"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func pollTestICS(summary string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:event\r\nSUMMARY:" + summary + "\r\nDTSTART:20240902T090000Z\r\nDTEND:20240902T100000Z\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+}
+
+func TestPollUntil(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	// The feed changes from the third request, each request is
+	// announced on 'requests'
+	var mu sync.Mutex
+	var times []time.Time
+	requests := make(chan int, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		n := len(times)
+		mu.Unlock()
+
+		etag, summary := `"1"`, "Planning"
+		if n >= 3 {
+			etag, summary = `"2"`, "Retro"
+		}
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+		} else {
+			w.Write([]byte(pollTestICS(summary)))
+		}
+		requests <- n
+	}))
+	defer server.Close()
+
+	mdPath := filepath.Join(t.TempDir(), "calendar.md")
+	conv := &conversion{
+		Dedupe:    parse.DefaultDedupeMode,
+		Sort:      parse.DefaultSortKeys,
+		Renderer:  &render.TableRenderer{},
+		Formatter: render.DefaultFormatter(),
+	}
+
+	var buf bytes.Buffer
+	stop := make(chan os.Signal, 1)
+	done := make(chan int)
+	go func() {
+		done <- testRunCommand(&buf).pollUntil(stop, interval, []icsSource{{Path: server.URL + "/calendar.ics"}}, mdPath, false, parse.FetchOptions{}, conv)
+	}()
+
+	for n := 0; n < 3; {
+		select {
+		case n = <-requests:
+		case <-time.After(10 * interval):
+			t.Fatalf("got %d requests, want 3", n)
+		}
+	}
+	stop <- os.Interrupt
+
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Errorf("got exit code %d, want 0", code)
+		}
+	case <-time.After(10 * interval):
+		t.Fatal("polling did not stop")
+	}
+
+	// Polls are an interval apart
+	mu.Lock()
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < interval*8/10 {
+			t.Errorf("got %s between polls %d and %d, want %s", gap, i, i+1, interval)
+		}
+	}
+	mu.Unlock()
+
+	// Rendered on the first poll and once the feed changed
+	output := buf.String()
+	if got := strings.Count(output, "ICS file converted"); got != 2 {
+		t.Errorf("rendered %d times, want 2:\n%s", got, output)
+	}
+	if !strings.Contains(output, "No changes, skipping.") || !strings.HasSuffix(output, "Stopped polling.\n") {
+		t.Errorf("output does not skip the unchanged poll and stop:\n%s", output)
+	}
+
+	markdown, err := os.ReadFile(mdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "Retro") {
+		t.Errorf("markdown is not of the changed feed:\n%s", markdown)
+	}
+}
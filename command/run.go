@@ -1,10 +1,14 @@
 package command
 
 import (
+	"errors"
 	"fmt"
 	"hmerritt/go-ics-to-markdown/parse"
+	"hmerritt/go-ics-to-markdown/render"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,16 +26,31 @@ func (c *RunCommand) Synopsis() string {
 
 func (c *RunCommand) Help() string {
 	helpText := `
-Usage: ics-to-markdown run [options] FILE
+Usage: ics-to-markdown run [options] FILE...
   
   Convert ICS file into Markdown table.
+
+  FILE can be a path, a URL, or '-' to read from stdin. Multiple
+  files are merged into one document, each can be given a name
+  with 'name=FILE' (shown with '--show-calendar').
+
+  CalDAV calendars are read with 'caldav://' or 'caldavs://' URLs,
+  of a calendar or of a user's calendar home (every calendar is
+  merged). Only events between '--start' and '--end' are fetched.
+
+  With '--dir', every ICS file in a directory is converted
+  separately, into the same tree under '--output' (default '.').
+
+  With '--watch', the markdown is regenerated whenever an
+  input file changes. With '--every', sources are fetched again
+  on an interval, and the markdown regenerated if they changed.
 `
 
 	return strings.TrimSpace(helpText)
 }
 
 func (c *RunCommand) Flags() *FlagMap {
-	return GetFlagMap(lo.Union(FlagNamesGlobal, []string{"start", "end"}))
+	return GetFlagMap(lo.Union(FlagNamesGlobal, []string{"start", "end", "range", "now", "match", "grep", "exclude", "where", "filter", "sort", "horizon", "timezone", "split-days", "format", "template", "columns", "date-format", "time-format", "locale", "output", "show-calendar", "dedupe", "dir", "recursive", "jobs", "watch", "every", "no-cache", "cache-ttl", "offline", "header", "user", "password-env", "token-env", "auth-host", "netrc", "timeout", "retries", "max-size", "max-redirects"}))
 }
 
 func (c *RunCommand) strictExit() {
@@ -41,102 +60,734 @@ func (c *RunCommand) strictExit() {
 	}
 }
 
-func (c *RunCommand) Run(args []string) int {
-	// Record the total duration of this command
-	timeStart := time.Now()
+// ICS file to convert, optionally named by the user
+type icsSource struct {
+	Alias string
+	Path  string
+}
 
-	// Initiate error slice and counter
-	// Collects errors
-	// TODO: make this a type + methods
-	// errorSlice := make([]error, 0, 1)
-	errorCount := 0
+var sourceAliasRegex = regexp.MustCompile(`^([\w][\w .-]*)=(.+)$`)
+
+// Split 'name=FILE' arguments, unless the whole argument is an existing file
+func parseSource(arg string) icsSource {
+	if matched := sourceAliasRegex.FindStringSubmatch(arg); matched != nil && !parse.FileExists(arg) {
+		return icsSource{Alias: matched[1], Path: matched[2]}
+	}
+	return icsSource{Path: arg}
+}
+
+// Options shared by every calendar converted in a run
+type conversion struct {
+	ParseOptions parse.ICSParseOptions
+	Filter       parse.ICSEventFilter
+	Dedupe       parse.DedupeMode
+	Sort         []parse.SortKey
+	SplitDays    bool
+	Renderer     render.Renderer
+	Formatter    *render.Formatter
+
+	// Output exactly as rendered, without formatting (templates)
+	Raw bool
+}
+
+// Markdown and event counts of a converted calendar
+type conversionResult struct {
+	Markdown string
+
+	EventsParsed   int
+	EventsTotal    int
+	EventsFiltered int
+
+	// Error formatting the markdown, which is then left as rendered
+	FormatErr error
+}
+
+// Dedupe, filter, sort and render the events of a calendar
+func (conv *conversion) convert(calendar *parse.ICSCalendar, mdPath string) (*conversionResult, error) {
+	result := &conversionResult{EventsParsed: len(calendar.Events)}
+
+	icsEventsTotal := parse.ICSEventsDedupe(calendar.Events, conv.Dedupe)
+	icsEvents := parse.ICSEventsFilter(icsEventsTotal, conv.Filter)
+
+	if conv.SplitDays {
+		icsEvents = parse.ICSEventsSplitDays(icsEvents)
+	}
+	parse.ICSEventsSort(icsEvents, conv.Sort)
+
+	result.EventsTotal = len(icsEventsTotal)
+	result.EventsFiltered = len(icsEvents)
+
+	markdownRendered, err := conv.Renderer.Render(&render.Document{
+		Events:              icsEvents,
+		HasEventValue:       calendar.HasEventValue,
+		CalendarName:        calendar.Name,
+		CalendarDescription: calendar.Description,
+		Start:               conv.Filter.Start,
+		End:                 conv.Filter.End,
+		GeneratedAt:         time.Now(),
+		Formatter:           conv.Formatter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates are output exactly as written
+	result.Markdown = markdownRendered
+	if !conv.Raw {
+		markdownFormatted, err := mdFmt.Process(mdPath, []byte(markdownRendered), nil)
+		if err == nil {
+			result.Markdown = string(markdownFormatted)
+		}
+		result.FormatErr = err
+	}
+
+	return result, nil
+}
 
+func (c *RunCommand) Run(args []string) int {
 	args = c.Flags().Parse(c.UI, args)
 
-	var icsPath string
+	// Keep stdout for the markdown when writing to it
+	flagOutput := fmt.Sprint(c.Flags().Get("output").Value)
+	toStdout := flagOutput == "-"
+	if toStdout {
+		c.UI.OutputToStderr()
+	}
 
-	if len(args) == 0 {
+	flagDir := fmt.Sprint(c.Flags().Get("dir").Value)
+	if flagDir != "" {
+		if len(args) > 0 {
+			c.UI.Error("Use either '--dir' or FILE arguments, not both.")
+			return 1
+		}
+		if toStdout {
+			c.UI.Error("The '--dir' flag writes a file per calendar, it can not output to stdout.")
+			return 1
+		}
+	}
+
+	var sources []icsSource
+
+	if len(args) == 0 && flagDir == "" {
 		// Use default ICS file
-		icsPath = parse.AddICSExtension(parse.ElasticExtension(parse.DefaultICSFileName))
+		icsPath := parse.AddICSExtension(parse.ElasticExtension(parse.DefaultICSFileName))
 		c.UI.Warn("No file entered.")
 		c.strictExit()
 		c.UI.Warn("Trying default '" + icsPath + "' instead.\n")
-	} else {
-		icsPath = parse.ElasticExtension(args[0])
+		sources = append(sources, icsSource{Path: icsPath})
+	}
+	for _, arg := range args {
+		source := parseSource(arg)
+
+		path, err := parse.ResolveSchemes(source.Path)
+		if err != nil {
+			c.UI.Error(fmt.Sprint(err))
+			return 1
+		}
+		source.Path = path
+		if !parse.IsStdin(source.Path) {
+			source.Path = parse.ElasticExtension(source.Path)
+		}
+		sources = append(sources, source)
 	}
 
 	mdPath := "calendar.md"
-	if parse.FileExists(icsPath) {
-		mdPath = fmt.Sprintf("%s.md", strings.TrimSuffix(filepath.Base(icsPath), ".ics"))
+	if len(sources) == 1 && parse.FileExists(sources[0].Path) {
+		mdPath = fmt.Sprintf("%s.md", strings.TrimSuffix(filepath.Base(sources[0].Path), ".ics"))
+	}
+	if flagOutput != "" && !toStdout {
+		mdPath = flagOutput
+	}
+
+	flagTimezone := fmt.Sprint(c.Flags().Get("timezone").Value)
+
+	var timezone *time.Location
+	filterLocation := time.Local
+	if flagTimezone != "" {
+		var err error
+		timezone, err = time.LoadLocation(flagTimezone)
+		if err != nil {
+			c.UI.Error("Unable to load timezone '" + flagTimezone + "'.")
+			c.UI.Warn("\nUse an IANA timezone name, for example 'Europe/London'.")
+			return 1
+		}
+		filterLocation = timezone
+	}
+
+	// Relative dates ('today', '+7d', 'next-month') are resolved against
+	// '--now', so scheduled reports can be reproduced
+	now := time.Now().In(filterLocation)
+	if flagNow := fmt.Sprint(c.Flags().Get("now").Value); flagNow != "" {
+		var err error
+		now, err = parse.ParseDateTime(flagNow, now)
+		if err != nil {
+			c.UI.Error("Unable to parse now '" + flagNow + "'.")
+			c.UI.Warn("\nUse a date, for example '2024-09-01', or an RFC 3339 timestamp, for example '2024-09-01T09:00:00Z'.")
+			return 1
+		}
 	}
 
 	flagStart := fmt.Sprint(c.Flags().Get("start").Value)
 	flagEnd := fmt.Sprint(c.Flags().Get("end").Value)
+	flagRange := fmt.Sprint(c.Flags().Get("range").Value)
 
-	filterStart, err := time.Parse("2006-01-02", flagStart)
-	if err != nil {
-		filterStart = time.Time{}
-		if flagStart != "" {
-			c.UI.Error("Unable to parse start date.")
+	var filterStart, filterEnd time.Time
+	if flagRange != "" {
+		if flagStart != "" || flagEnd != "" {
+			c.UI.Error("The '--range' flag can not be used with '--start' or '--end'.")
+			return 1
+		}
+
+		dateRange, err := parse.ParseDateRange(flagRange, now)
+		if err != nil || !dateRange.End.After(dateRange.Start) {
+			c.UI.Error("Unable to parse range '" + flagRange + "'.")
+			c.UI.Warn("\nUse a period, for example 'today', 'this-week', 'next-month', '2024-Q3', '2024-W33' or '--range=-2w'.")
+			return 1
+		}
+		filterStart, filterEnd = dateRange.Start, dateRange.End
+	}
+	if flagStart != "" {
+		var err error
+		filterStart, err = parse.ParseDateTime(flagStart, now)
+		if err != nil {
+			c.UI.Error("Unable to parse start date '" + flagStart + "'.")
+			c.UI.Warn("\nUse a date, for example '2024-09-01', an RFC 3339 timestamp, or a relative date, for example 'today', 'this-month' or '--start=-2w'.")
+			return 1
+		}
+	}
+	if flagEnd != "" {
+		var err error
+		filterEnd, err = parse.ParseDateTime(flagEnd, now)
+		if err != nil {
+			c.UI.Error("Unable to parse end date '" + flagEnd + "'.")
+			c.UI.Warn("\nUse a date, for example '2024-09-30', an RFC 3339 timestamp, or a relative date, for example 'tomorrow', '+7d' or 'next-month'.")
+			return 1
+		}
+	}
+	if !filterStart.IsZero() && !filterEnd.IsZero() && !filterEnd.After(filterStart) {
+		c.UI.Error("The end date must be after the start date.")
+		return 1
+	}
+
+	textFilters, ok := c.textFilters()
+	if !ok {
+		return 1
+	}
+
+	var query *parse.Query
+	if flagFilter := fmt.Sprint(c.Flags().Get("filter").Value); flagFilter != "" {
+		var err error
+		query, err = parse.ParseQuery(flagFilter, now)
+		if err != nil {
+			c.UI.Error("Unable to parse filter.")
+			c.UI.Error("\n  " + flagFilter)
+
+			// Point at the error
+			var queryErr *parse.QueryError
+			if errors.As(err, &queryErr) {
+				c.UI.Error("  " + strings.Repeat(" ", queryErr.Column-1) + "^ " + queryErr.Message)
+			} else {
+				c.UI.Error(fmt.Sprint(err))
+			}
+			return 1
 		}
 	}
-	filterEnd, err := time.Parse("2006-01-02", flagEnd)
+
+	var sortKeys []parse.SortKey
+	if flagSort := fmt.Sprint(c.Flags().Get("sort").Value); flagSort != "" {
+		var err error
+		sortKeys, err = parse.ParseSortKeys(flagSort)
+		if err != nil {
+			c.UI.Error(fmt.Sprint(err))
+			c.UI.Warn("\nUse a comma separated list of fields, with ':desc' for descending, for example 'start,duration:desc,summary'.")
+			return 1
+		}
+	}
+
+	flagMatch := fmt.Sprint(c.Flags().Get("match").Value)
+	if flagMatch == "" {
+		flagMatch = fmt.Sprint(c.Flags().Get("match").Default)
+	}
+
+	matchMode, err := parse.ParseMatchMode(flagMatch)
 	if err != nil {
-		filterEnd = time.Time{}
-		if flagEnd != "" {
-			c.UI.Error("Unable to parse end date.")
+		c.UI.Error(fmt.Sprint(err))
+		return 1
+	}
+
+	flagHorizon := fmt.Sprint(c.Flags().Get("horizon").Value)
+	if flagHorizon == "" {
+		flagHorizon = fmt.Sprint(c.Flags().Get("horizon").Default)
+	}
+
+	horizon, err := parse.ParseDuration(flagHorizon)
+	if err != nil || horizon <= 0 {
+		c.UI.Error("Unable to parse horizon '" + flagHorizon + "'.")
+		c.UI.Warn("\nUse a positive duration, for example '90d' or '52w'.")
+		return 1
+	}
+
+	flagDedupe := fmt.Sprint(c.Flags().Get("dedupe").Value)
+	if flagDedupe == "" {
+		flagDedupe = fmt.Sprint(c.Flags().Get("dedupe").Default)
+	}
+
+	dedupeMode, err := parse.ParseDedupeMode(flagDedupe)
+	if err != nil {
+		c.UI.Error("Unable to use dedupe mode '" + flagDedupe + "'.")
+		c.UI.Warn("\nUse one of 'off', 'uid' or 'fuzzy'.")
+		return 1
+	}
+
+	flagFormat := fmt.Sprint(c.Flags().Get("format").Value)
+	flagTemplate := fmt.Sprint(c.Flags().Get("template").Value)
+
+	var renderer render.Renderer
+	if flagTemplate != "" {
+		if flagFormat != "" {
+			c.UI.Error("Use either '--format' or '--template', not both.")
+			return 1
+		}
+
+		renderer, err = render.NewTemplateRenderer(flagTemplate)
+		if err != nil {
+			c.UI.Error("Unable to load template.")
+			c.UI.Error(fmt.Sprint(err))
+			return 1
+		}
+	} else {
+		if flagFormat == "" {
+			flagFormat = fmt.Sprint(c.Flags().Get("format").Default)
+		}
+
+		renderer, err = render.Get(flagFormat)
+		if err != nil {
+			c.UI.Error("Unknown format '" + flagFormat + "'.")
+			c.UI.Warn("\nAvailable formats: " + strings.Join(render.Names(), ", "))
+			return 1
+		}
+	}
+
+	flagColumns := fmt.Sprint(c.Flags().Get("columns").Value)
+	showCalendar := c.Flags().Get("show-calendar").Value == true
+	_, isTable := renderer.(*render.TableRenderer)
+
+	// Columns set in the environment are a default for tables only
+	columnsFrom := "'--columns'"
+	if flagColumns == "" && isTable {
+		flagColumns = os.Getenv(columnsEnv)
+		columnsFrom = columnsEnv
+	}
+
+	if flagColumns != "" || showCalendar {
+		if !isTable {
+			c.UI.Error("The '--columns' and '--show-calendar' flags only apply to the 'table' format.")
+			return 1
 		}
+
+		var columns []render.Column
+		if flagColumns != "" {
+			columns, err = render.ParseColumns(flagColumns)
+			if err != nil {
+				c.UI.Error("Unable to parse columns from " + columnsFrom + ".")
+				c.UI.Error(fmt.Sprint(err))
+				return 1
+			}
+		}
+		renderer = &render.TableRenderer{Columns: columns, ShowCalendar: showCalendar}
+	}
+
+	flagLocale := fmt.Sprint(c.Flags().Get("locale").Value)
+	if flagLocale == "" {
+		flagLocale = fmt.Sprint(c.Flags().Get("locale").Default)
 	}
 
-	icsData, err, isURL := parse.FetchICS(icsPath)
+	formatter, err := render.NewFormatter(
+		flagLocale,
+		fmt.Sprint(c.Flags().Get("date-format").Value),
+		fmt.Sprint(c.Flags().Get("time-format").Value),
+	)
 	if err != nil {
-		if isURL {
-			c.UI.Error("Unable to fetch URL data.")
+		c.UI.Error("Unable to use date/time format.")
+		c.UI.Error(fmt.Sprint(err))
+		return 1
+	}
+
+	conv := &conversion{
+		ParseOptions: parse.ICSParseOptions{
+			Start:    filterStart,
+			End:      filterEnd,
+			Horizon:  horizon,
+			Now:      now,
+			Timezone: timezone,
+		},
+		Filter: parse.ICSEventFilter{
+			Start:   filterStart,
+			End:     filterEnd,
+			Match:   matchMode,
+			Include: textFilters.Include,
+			Where:   textFilters.Where,
+			Exclude: textFilters.Exclude,
+			Query:   query,
+		},
+		Dedupe:    dedupeMode,
+		Sort:      sortKeys,
+		SplitDays: c.Flags().Get("split-days").Value == true,
+		Renderer:  renderer,
+		Formatter: formatter,
+		Raw:       flagTemplate != "",
+	}
+
+	watch := c.Flags().Get("watch").Value == true
+	if watch {
+		if lo.SomeBy(sources, func(source icsSource) bool { return !parse.FileExists(source.Path) }) {
+			c.UI.Error("The '--watch' flag only works with local files.")
+			return 1
+		}
+	}
+
+	fetchOptions := parse.FetchOptions{
+		Offline: c.Flags().Get("offline").Value == true,
+		Start:   filterStart,
+		End:     filterEnd,
+	}
+
+	if c.Flags().Get("no-cache").Value != true {
+		flagCacheTTL := fmt.Sprint(c.Flags().Get("cache-ttl").Value)
+		if flagCacheTTL == "" {
+			flagCacheTTL = fmt.Sprint(c.Flags().Get("cache-ttl").Default)
+		}
+
+		cacheTTL, err := parse.ParseDuration(flagCacheTTL)
+		if err != nil || cacheTTL < 0 {
+			c.UI.Error("Unable to parse cache TTL '" + flagCacheTTL + "'.")
+			c.UI.Warn("\nUse a duration, for example '1h', or '0' to always revalidate.")
+			return 1
+		}
+
+		fetchOptions.Cache, err = parse.NewUserHTTPCache(cacheTTL)
+		if err != nil {
+			c.UI.Warn("Unable to use the cache directory, URLs will not be cached.")
+			c.UI.Warn(fmt.Sprint(err) + "\n")
+		}
+	}
+
+	auth, ok := c.httpAuth(sources)
+	if !ok {
+		return 1
+	}
+	fetchOptions.Auth = auth
+
+	if !c.fetchLimits(&fetchOptions) {
+		return 1
+	}
+
+	if fetchOptions.Offline && fetchOptions.Cache == nil {
+		c.UI.Error("The '--offline' flag needs the cache, it can not be used with '--no-cache'.")
+		return 1
+	}
+
+	if flagEvery := fmt.Sprint(c.Flags().Get("every").Value); flagEvery != "" {
+		every, err := parse.ParseDuration(flagEvery)
+		if err != nil || every <= 0 {
+			c.UI.Error("Unable to parse interval '" + flagEvery + "'.")
+			c.UI.Warn("\nUse a positive duration, for example '15m' or '1h'.")
+			return 1
+		}
+		if watch || flagDir != "" || fetchOptions.Offline || lo.SomeBy(sources, func(source icsSource) bool { return parse.IsStdin(source.Path) }) {
+			c.UI.Error("The '--every' flag can not be used with '--watch', '--dir', '--offline' or stdin.")
+			return 1
+		}
+
+		return c.poll(every, sources, mdPath, toStdout, fetchOptions, conv)
+	}
+
+	rebuild := func() int {
+		if flagDir != "" {
+			return c.runDir(flagDir, flagOutput, conv)
+		}
+		return c.convertSources(sources, mdPath, toStdout, fetchOptions, conv)
+	}
+
+	exitCode := rebuild()
+	if watch {
+		var files []string
+		for _, source := range sources {
+			files = append(files, source.Path)
+		}
+		return c.watch(files, flagDir, rebuild)
+	}
+
+	return exitCode
+}
+
+// Text filters of the events, from '--grep', '--where' and '--exclude'
+func (c *RunCommand) textFilters() (parse.ICSEventFilter, bool) {
+	var filter parse.ICSEventFilter
+
+	for _, flag := range []struct {
+		name    string
+		parse   func(string) (parse.TextFilter, error)
+		filters *[]parse.TextFilter
+	}{
+		{"grep", parse.ParseTextFilter, &filter.Include},
+		{"where", parse.ParseWhere, &filter.Where},
+		{"exclude", parse.ParseTextFilter, &filter.Exclude},
+	} {
+		values, _ := c.Flags().Get(flag.name).Value.([]string)
+		for _, value := range values {
+			textFilter, err := flag.parse(value)
+			if err != nil {
+				c.UI.Error("Unable to use '--" + flag.name + "' filter.")
+				c.UI.Error(fmt.Sprint(err))
+				return filter, false
+			}
+			*flag.filters = append(*flag.filters, textFilter)
+		}
+	}
+
+	return filter, true
+}
+
+// Credentials and headers for fetching URLs, from the auth flags.
+//
+// Secrets are read from the environment or a netrc file, and never printed.
+// Headers, basic auth and bearer tokens are only sent to one host.
+func (c *RunCommand) httpAuth(sources []icsSource) (*parse.HTTPAuth, bool) {
+	auth := &parse.HTTPAuth{Headers: make(map[string]string)}
+
+	headers, _ := c.Flags().Get("header").Value.([]string)
+	for _, header := range headers {
+		name, value, err := parse.ParseHeader(header)
+		if err != nil {
 			c.UI.Error(fmt.Sprint(err))
-			c.UI.Warn("\nMake sure the link is accessible and try again.")
+			return nil, false
+		}
+		auth.Headers[name] = value
+	}
 
-		} else {
-			c.UI.Error("Unable to open file.")
+	auth.Username = fmt.Sprint(c.Flags().Get("user").Value)
+	if flagPasswordEnv := fmt.Sprint(c.Flags().Get("password-env").Value); flagPasswordEnv != "" {
+		if auth.Username == "" {
+			c.UI.Error("The '--password-env' flag needs a '--user'.")
+			return nil, false
+		}
+		auth.Password = os.Getenv(flagPasswordEnv)
+		if auth.Password == "" {
+			c.UI.Error("Environment variable '" + flagPasswordEnv + "' is not set.")
+			return nil, false
+		}
+	}
+
+	if flagTokenEnv := fmt.Sprint(c.Flags().Get("token-env").Value); flagTokenEnv != "" {
+		auth.BearerToken = os.Getenv(flagTokenEnv)
+		if auth.BearerToken == "" {
+			c.UI.Error("Environment variable '" + flagTokenEnv + "' is not set.")
+			return nil, false
+		}
+	}
+
+	hosts := lo.Uniq(lo.FilterMap(sources, func(source icsSource, _ int) (string, bool) {
+		host := parse.URLHost(source.Path)
+		return host, host != ""
+	}))
+	if flagAuthHost := fmt.Sprint(c.Flags().Get("auth-host").Value); flagAuthHost != "" {
+		auth.Host = flagAuthHost
+	} else if len(hosts) > 0 {
+		auth.Host = hosts[0]
+	}
+
+	explicit := len(auth.Headers) > 0 || auth.Username != "" || auth.BearerToken != ""
+	if explicit && len(hosts) > 1 && fmt.Sprint(c.Flags().Get("auth-host").Value) == "" {
+		c.UI.Error("The URLs are on different hosts (" + strings.Join(hosts, ", ") + "), '--header', '--user' and '--token-env' are only sent to one.")
+		c.UI.Warn("\nUse '--auth-host' to choose the host, or '--netrc' for credentials per host.")
+		return nil, false
+	}
+
+	if flagNetrc := fmt.Sprint(c.Flags().Get("netrc").Value); flagNetrc != "" {
+		entries, err := parse.ParseNetrc(flagNetrc)
+		if err != nil {
+			c.UI.Error("Unable to read netrc file.")
 			c.UI.Error(fmt.Sprint(err))
+			return nil, false
+		}
+		auth.Netrc = entries
+	}
+
+	return auth, true
+}
+
+// Timeouts, retries and size limits for fetching URLs, from the flags
+func (c *RunCommand) fetchLimits(fetchOptions *parse.FetchOptions) bool {
+	if flagTimeout := fmt.Sprint(c.Flags().Get("timeout").Value); flagTimeout != "" {
+		timeout, err := parse.ParseDuration(flagTimeout)
+		if err != nil || timeout <= 0 {
+			c.UI.Error("Unable to parse timeout '" + flagTimeout + "'.")
+			c.UI.Warn("\nUse a positive duration, for example '10s' or '2m'.")
+			return false
+		}
+		fetchOptions.Timeout = timeout
+	}
+
+	if flagRetries := fmt.Sprint(c.Flags().Get("retries").Value); flagRetries != "" {
+		retries, err := strconv.Atoi(flagRetries)
+		if err != nil || retries < 0 {
+			c.UI.Error("Unable to parse retries '" + flagRetries + "'.")
+			c.UI.Warn("\nUse a number of retries, or '0' to never retry.")
+			return false
+		}
+		fetchOptions.Attempts = retries + 1
+	}
+
+	if flagMaxSize := fmt.Sprint(c.Flags().Get("max-size").Value); flagMaxSize != "" {
+		maxSize, err := parse.ParseSize(flagMaxSize)
+		if err != nil || maxSize <= 0 {
+			c.UI.Error("Unable to parse max size '" + flagMaxSize + "'.")
+			c.UI.Warn("\nUse a positive size, for example '500KB' or '100MB'.")
+			return false
+		}
+		fetchOptions.MaxSize = maxSize
+	}
+
+	if flagMaxRedirects := fmt.Sprint(c.Flags().Get("max-redirects").Value); flagMaxRedirects != "" {
+		maxRedirects, err := strconv.Atoi(flagMaxRedirects)
+		if err != nil || maxRedirects <= 0 {
+			c.UI.Error("Unable to parse max redirects '" + flagMaxRedirects + "'.")
+			c.UI.Warn("\nUse a positive number of redirects.")
+			return false
+		}
+		fetchOptions.MaxRedirects = maxRedirects
+	}
+
+	return true
+}
+
+// Explain why a URL could not be fetched, with a hint for fixing it
+func (c *RunCommand) reportFetchError(result parse.FetchResult) {
+	c.UI.Error("Unable to fetch URL data '" + parse.RedactURL(result.Path) + "'.")
+	c.UI.Error(fmt.Sprint(result.Err))
+
+	var statusErr *parse.HTTPStatusError
+	var tooLargeErr *parse.ResponseTooLargeError
+	var redirectsErr *parse.TooManyRedirectsError
+
+	switch {
+	case errors.As(result.Err, &statusErr) && (statusErr.StatusCode == 401 || statusErr.StatusCode == 403):
+		c.UI.Warn("\nThe feed needs credentials, see '--user', '--token-env', '--netrc' and '--header'.")
+	case errors.As(result.Err, &statusErr) && statusErr.StatusCode == 404:
+		c.UI.Warn("\nThe feed was not found, check the link and try again.")
+	case errors.As(result.Err, &statusErr) && statusErr.Temporary():
+		c.UI.Warn("\nThe server is having problems, try again later.")
+	case errors.As(result.Err, &tooLargeErr):
+		c.UI.Warn("\nUse '--max-size' to allow larger calendars.")
+	case errors.As(result.Err, &redirectsErr):
+		c.UI.Warn("\nUse '--max-redirects' to follow more redirects.")
+	case parse.IsTimeout(result.Err):
+		c.UI.Warn("\nThe request timed out, use '--timeout' to wait longer.")
+	default:
+		c.UI.Warn("\nMake sure the link is accessible and try again.")
+	}
+}
+
+// Fetch, merge and convert sources into a single markdown file
+func (c *RunCommand) convertSources(sources []icsSource, mdPath string, toStdout bool, fetchOptions parse.FetchOptions, conv *conversion) int {
+	// Record the total duration of this conversion
+	timeStart := time.Now()
+
+	// Fetch all sources concurrently
+	fetched := parse.FetchICSAll(sourcePaths(sources), fetchOptions)
+
+	return c.convertFetched(sources, fetched, mdPath, toStdout, conv, timeStart)
+}
+
+func sourcePaths(sources []icsSource) []string {
+	return lo.Map(sources, func(source icsSource, _ int) string {
+		return source.Path
+	})
+}
+
+// Merge and convert fetched sources into a single markdown file
+func (c *RunCommand) convertFetched(sources []icsSource, fetched []parse.FetchResult, mdPath string, toStdout bool, conv *conversion, timeStart time.Time) int {
+	// Initiate error slice and counter
+	// Collects errors
+	// TODO: make this a type + methods
+	// errorSlice := make([]error, 0, 1)
+	errorCount := 0
+
+	fetchFailed := false
+	for _, result := range fetched {
+		if result.Err == nil {
+			continue
+		}
+		fetchFailed = true
+
+		if result.IsURL {
+			c.reportFetchError(result)
+		} else if parse.IsStdin(result.Path) {
+			c.UI.Error("Unable to read from stdin.")
+			c.UI.Error(fmt.Sprint(result.Err))
+		} else {
+			c.UI.Error("Unable to open file '" + result.Path + "'.")
+			c.UI.Error(fmt.Sprint(result.Err))
 			c.UI.Warn("\nCheck the file is exists and try again.")
-			os.Exit(2)
 		}
+	}
+	if fetchFailed {
 		return 2
 	}
 
-	icsEventsTotal, hasEventValue, err := parse.IcsToEvents(icsData)
+	var calendars []*parse.ICSCalendar
+	for i, result := range fetched {
+		calendar, err := parse.IcsToCalendar(result.Data, conv.ParseOptions)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error parsing ICS file '%s': %v\n", parse.RedactURL(result.Path), parse.RedactText(err.Error())))
+			return 1
+		}
+
+		if sources[i].Alias != "" {
+			calendar.Rename(sources[i].Alias)
+		}
+		calendars = append(calendars, calendar)
+	}
+
+	calendar := parse.MergeCalendars(calendars)
+
+	converted, err := conv.convert(calendar, mdPath)
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("Error parsing ICS file: %v\n", err))
+		c.UI.Error(fmt.Sprintf("Error rendering markdown: %v\n", err))
 		return 1
 	}
 
-	icsEvents := parse.ICSEventsFilter(icsEventsTotal, parse.ICSEventFilter{
-		Start: filterStart,
-		End:   filterEnd,
-	})
-
 	// Print ICS file stats
-	c.UI.Output("ICS File")
-	c.UI.Output("├── Events in total       " + fmt.Sprint(len(icsEventsTotal)))
-	c.UI.Output("└── Events after filters  " + fmt.Sprint(len(icsEvents)))
+	if len(calendars) > 1 {
+		c.UI.Output(fmt.Sprintf("ICS Files (%d merged)", len(calendars)))
+	} else {
+		c.UI.Output("ICS File")
+	}
+	c.UI.Output("├── Events in total       " + fmt.Sprint(converted.EventsTotal))
+	if duplicates := converted.EventsParsed - converted.EventsTotal; duplicates > 0 {
+		c.UI.Output("├── Duplicates removed    " + fmt.Sprint(duplicates))
+	}
+	c.UI.Output("└── Events after filters  " + fmt.Sprint(converted.EventsFiltered))
 	c.UI.Output("")
 
-	markdownFinal := ""
-	markdownTable := parse.ICSEventsToMarkdown(icsEvents, hasEventValue)
-	markdownFormatted, err := mdFmt.Process(mdPath, []byte(markdownTable), nil)
-
-	if err == nil {
-		markdownFinal = string(markdownFormatted)
-	} else {
-		markdownFinal = markdownTable
-		c.UI.Error(fmt.Sprintf("Error formatting markdown: %v\n", err))
+	markdownFinal := converted.Markdown
+	if converted.FormatErr != nil {
+		c.UI.Error(fmt.Sprintf("Error formatting markdown: %v\n", converted.FormatErr))
 		errorCount++
 		c.strictExit()
 	}
 
-	err = os.WriteFile(mdPath, []byte(markdownFinal), 0644)
+	if toStdout {
+		_, err = os.Stdout.WriteString(markdownFinal)
+	} else {
+		err = os.MkdirAll(filepath.Dir(mdPath), 0755)
+		if err == nil {
+			err = os.WriteFile(mdPath, []byte(markdownFinal), 0644)
+		}
+	}
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("Error writing to file: %v\n", err))
+		c.UI.Error(fmt.Sprintf("Error writing output: %v\n", err))
 		errorCount++
 		c.strictExit()
 	}
@@ -0,0 +1,162 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+	"hmerritt/go-ics-to-markdown/ui"
+)
+
+// Outcome of converting one file with '--dir'
+type dirResult struct {
+	Path   string
+	MdPath string
+	Events int
+	Err    error
+}
+
+// Convert every ICS file in 'dir', writing markdown files into
+// the same tree under 'outDir'
+func (c *RunCommand) runDir(dir string, outDir string, conv *conversion) int {
+	timeStart := time.Now()
+
+	if outDir == "" {
+		outDir = "."
+	}
+
+	files, err := findICSFiles(dir, c.Flags().Get("recursive").Value == true)
+	if err != nil {
+		c.UI.Error("Unable to read directory files")
+		c.UI.Error(fmt.Sprint(err))
+		return 1
+	}
+
+	if len(files) == 0 {
+		c.UI.Output("No ICS files found in the '" + dir + "' directory.")
+		return 2
+	}
+
+	jobs, _ := c.Flags().Get("jobs").Value.(int)
+	if jobs <= 0 {
+		jobs, _ = c.Flags().Get("jobs").Default.(int)
+	}
+
+	results := make([]dirResult, len(files))
+	bar := ui.GetProgressBar(len(files), "Converting")
+
+	// Bounded pool of workers, each converting one file at a time
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < min(jobs, len(files)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = convertFile(dir, files[i], outDir, conv)
+				bar.Add(1)
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	bar.Finish()
+	c.UI.Output("\n")
+
+	// Per-file summary
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			c.UI.Error(fmt.Sprintf("✗ %s: %v", result.Path, result.Err))
+			continue
+		}
+		c.UI.Output(fmt.Sprintf("%s %s → %s (%d events)", c.UI.Colorize("✓", c.UI.SuccessColor), result.Path, result.MdPath, result.Events))
+	}
+	c.UI.Output("")
+
+	if failed > 0 {
+		c.UI.Output(fmt.Sprintf("%s in %s", c.UI.Colorize(fmt.Sprintf("%d of %d ICS files converted (%d failed)", len(files)-failed, len(files), failed), c.UI.WarnColor), time.Since(timeStart)))
+		return 1
+	}
+
+	c.UI.Output(fmt.Sprintf("%s in %s", c.UI.Colorize(fmt.Sprintf("%d ICS files converted", len(files)), c.UI.SuccessColor), time.Since(timeStart)))
+	return 0
+}
+
+// Paths of the ICS files in 'dir', relative to it and sorted
+func findICSFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if parse.IsICSFile(entry.Name()) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+
+	sort.Strings(files)
+	return files, err
+}
+
+func convertFile(dir string, file string, outDir string, conv *conversion) dirResult {
+	mdPath := filepath.Join(outDir, strings.TrimSuffix(file, filepath.Ext(file))+".md")
+	result := dirResult{Path: file, MdPath: mdPath}
+
+	icsData, err := parse.FetchFile(filepath.Join(dir, file))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	calendar, err := parse.IcsToCalendar(icsData, conv.ParseOptions)
+	if err != nil {
+		result.Err = fmt.Errorf("error parsing ICS file: %v", err)
+		return result
+	}
+
+	converted, err := conv.convert(calendar, mdPath)
+	if err != nil {
+		result.Err = fmt.Errorf("error rendering markdown: %v", err)
+		return result
+	}
+	result.Events = converted.EventsFiltered
+
+	if err = os.MkdirAll(filepath.Dir(mdPath), 0755); err == nil {
+		err = os.WriteFile(mdPath, []byte(converted.Markdown), 0644)
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("error writing output: %v", err)
+		return result
+	}
+
+	// The file is written as rendered, but still counts as failed
+	if converted.FormatErr != nil {
+		result.Err = fmt.Errorf("error formatting markdown: %v", converted.FormatErr)
+	}
+	return result
+}
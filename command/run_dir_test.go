@@ -0,0 +1,108 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const dirTestICS = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+	"BEGIN:VEVENT\r\nUID:standup\r\nSUMMARY:Standup\r\nDTSTART:20240902T090000Z\r\nDTEND:20240902T091500Z\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// Directory with two calendars, a file which is not a calendar, one
+// which can not be read, and a calendar in a sub-directory
+func testICSDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"work.ics":         dirTestICS,
+		"home.ics":         dirTestICS,
+		"notes.txt":        "not a calendar",
+		"archive/2023.ics": dirTestICS,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A dangling link can not be read, even by root
+	if err := os.Symlink(filepath.Join(dir, "missing.ics"), filepath.Join(dir, "broken.ics")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRunDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		written []string
+	}{
+		{"top level only", nil, []string{"home.md", "work.md"}},
+		{"recursive", []string{"--recursive"}, []string{"archive/2023.md", "home.md", "work.md"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := testICSDir(t)
+			outDir := t.TempDir()
+
+			var buf bytes.Buffer
+			code := testRunCommand(&buf).Run(append([]string{"--dir", dir, "--output", outDir}, test.args...))
+
+			// The unreadable file fails, without stopping the others
+			if code != 1 {
+				t.Errorf("got exit code %d, want 1", code)
+			}
+			output := buf.String()
+			if !strings.Contains(output, "✗ broken.ics: ") {
+				t.Errorf("output %q does not report broken.ics", output)
+			}
+			if !strings.Contains(output, "(1 failed)") {
+				t.Errorf("output %q does not count the failed file", output)
+			}
+
+			var written []string
+			err := filepath.WalkDir(outDir, func(path string, entry os.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					rel, _ := filepath.Rel(outDir, path)
+					written = append(written, filepath.ToSlash(rel))
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(written, ",") != strings.Join(test.written, ",") {
+				t.Errorf("got files %v, want %v", written, test.written)
+			}
+
+			markdown, err := os.ReadFile(filepath.Join(outDir, "work.md"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(markdown), "Standup") {
+				t.Errorf("work.md does not contain the event:\n%s", markdown)
+			}
+		})
+	}
+}
+
+func TestRunDirEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if code := testRunCommand(&buf).Run([]string{"--dir", t.TempDir(), "--output", t.TempDir()}); code != 2 {
+		t.Errorf("got exit code %d, want 2", code)
+	}
+	if !strings.Contains(buf.String(), "No ICS files found") {
+		t.Errorf("output %q does not say no files were found", buf.String())
+	}
+}
@@ -0,0 +1,70 @@
+package command
+
+import (
+	"bytes"
+	"hmerritt/go-ics-to-markdown/ui"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+// Run command writing all of its output to buf
+func testRunCommand(buf *bytes.Buffer) *RunCommand {
+	return &RunCommand{
+		BaseCommand: &BaseCommand{
+			UI: &ui.Ui{
+				ColoredUi: &cli.ColoredUi{
+					InfoColor:  cli.UiColorNone,
+					ErrorColor: cli.UiColorNone,
+					WarnColor:  cli.UiColorNone,
+					Ui:         &cli.BasicUi{Writer: buf, ErrorWriter: buf},
+				},
+				SuccessColor: cli.UiColorNone,
+			},
+		},
+	}
+}
+
+func TestRunRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a calendar"))
+	}))
+	defer server.Close()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	secretURL := strings.Replace(server.URL, "http://", "http://alice:s3cret@", 1)
+	output := filepath.Join(t.TempDir(), "calendar.md")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"unparsable feed", []string{secretURL + "/bad.ics", "--no-cache", "--output", output}, "Error parsing ICS file"},
+		{"offline cache miss", []string{secretURL + "/other.ics", "--offline", "--output", output}, "has not been cached yet"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if code := testRunCommand(&buf).Run(test.args); code == 0 {
+				t.Errorf("got exit code 0, want an error")
+			}
+
+			got := buf.String()
+			if !strings.Contains(got, test.want) {
+				t.Errorf("output %q does not contain %q", got, test.want)
+			}
+			if strings.Contains(got, "s3cret") {
+				t.Errorf("output contains the password: %q", got)
+			}
+			if !strings.Contains(got, "alice:xxxxx@") {
+				t.Errorf("output %q does not contain the redacted URL", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Wait this long after the last change before rebuilding, so a
+// burst of writes (e.g. from a sync client) only rebuilds once
+const watchDebounce = 500 * time.Millisecond
+
+// Call 'rebuild' whenever one of 'files', or an ICS file in 'dir', changes.
+//
+// Runs until interrupted.
+func (c *RunCommand) watch(files []string, dir string, rebuild func() int) int {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.UI.Error("Unable to watch files.")
+		c.UI.Error(fmt.Sprint(err))
+		return 1
+	}
+	defer watcher.Close()
+
+	// Directories are watched rather than files, editors often
+	// replace a file when saving it, which would end the watch
+	watchedFiles := make(map[string]bool)
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err == nil {
+			watchedFiles[path] = true
+			err = watcher.Add(filepath.Dir(path))
+		}
+		if err != nil {
+			c.UI.Error("Unable to watch '" + file + "'.")
+			c.UI.Error(fmt.Sprint(err))
+			return 1
+		}
+	}
+
+	recursive := c.Flags().Get("recursive").Value == true
+	if dir != "" {
+		if err := watchDir(watcher, dir, recursive); err != nil {
+			c.UI.Error("Unable to watch '" + dir + "'.")
+			c.UI.Error(fmt.Sprint(err))
+			return 1
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	c.UI.Info("\nWatching for changes, press Ctrl+C to stop.")
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return 0
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			// Watch new subdirectories
+			if dir != "" && recursive && event.Has(fsnotify.Create) {
+				if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+					watchDir(watcher, event.Name, true)
+					continue
+				}
+			}
+
+			path, _ := filepath.Abs(event.Name)
+			if !watchedFiles[path] && !(dir != "" && parse.IsICSFile(path)) {
+				continue
+			}
+			debounce = time.After(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return 0
+			}
+			c.UI.Error(fmt.Sprintf("Error watching files: %v", err))
+
+		case <-debounce:
+			debounce = nil
+			c.UI.Info(fmt.Sprintf("\n[%s] Change detected, rebuilding...\n", time.Now().Format("15:04:05")))
+			rebuild()
+
+		case <-interrupt:
+			c.UI.Output("\nStopped watching.")
+			return 0
+		}
+	}
+}
+
+// Watch a directory, and its subdirectories if 'recursive'
+func watchDir(watcher *fsnotify.Watcher, dir string, recursive bool) error {
+	if !recursive {
+		return watcher.Add(dir)
+	}
+
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
@@ -7,6 +7,7 @@ require (
 	github.com/arran4/golang-ical v0.3.1
 	github.com/briandowns/spinner v1.23.1
 	github.com/fatih/color v1.17.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/imroc/req v0.3.2
 	github.com/jessevdk/go-flags v1.6.1
 	github.com/magefile/mage v1.15.0
@@ -29,7 +30,6 @@ require (
 	github.com/bgentry/speakeasy v0.1.0 // indirect
 	github.com/bitfield/gotestdox v0.2.2 // indirect
 	github.com/dnephin/pflag v1.0.7 // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
 	github.com/google/uuid v1.1.2 // indirect
 	github.com/hashicorp/errwrap v1.0.0 // indirect
@@ -0,0 +1,247 @@
+package parse
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/imroc/req"
+)
+
+// Credentials and extra headers sent when fetching URLs.
+//
+// Headers, basic auth and bearer tokens are only sent to Host,
+// netrc entries only to their own host.
+type HTTPAuth struct {
+	// Host the headers, basic auth and bearer token are sent to, e.g.
+	// 'example.com' (any port) or 'example.com:8443'. When empty, they
+	// are sent to every URL.
+	Host string
+
+	// Extra request headers, these take priority over other credentials
+	Headers map[string]string
+
+	// Basic auth
+	Username string
+	Password string
+
+	// Sent as 'Authorization: Bearer <token>'
+	BearerToken string
+
+	// Credentials by host, for URLs without other credentials
+	Netrc []NetrcEntry
+}
+
+// Machine entry of a netrc-style file.
+//
+// Entries with a login are used for basic auth, entries
+// with only a password are used as a bearer token.
+type NetrcEntry struct {
+	// Host name, empty for the 'default' entry
+	Machine  string
+	Login    string
+	Password string
+}
+
+func (a *HTTPAuth) header(rawURL string) req.Header {
+	header := req.Header{}
+	explicit := a.matchesHost(rawURL)
+
+	switch {
+	case explicit && a.Username != "":
+		header["Authorization"] = basicAuth(a.Username, a.Password)
+	case explicit && a.BearerToken != "":
+		header["Authorization"] = "Bearer " + a.BearerToken
+	default:
+		if entry := a.netrcEntry(rawURL); entry != nil {
+			if entry.Login != "" {
+				header["Authorization"] = basicAuth(entry.Login, entry.Password)
+			} else {
+				header["Authorization"] = "Bearer " + entry.Password
+			}
+		}
+	}
+
+	if explicit {
+		for name, value := range a.Headers {
+			header[name] = value
+		}
+	}
+	return header
+}
+
+// Checks if the headers, basic auth and bearer token are sent to a URL
+func (a *HTTPAuth) matchesHost(rawURL string) bool {
+	if a.Host == "" {
+		return true
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host, port, err := net.SplitHostPort(a.Host)
+	if err != nil {
+		return strings.EqualFold(u.Hostname(), strings.Trim(a.Host, "[]"))
+	}
+	return strings.EqualFold(u.Hostname(), host) && urlPort(u) == port
+}
+
+// Host of a URL which credentials are scoped to, with the port unless
+// it is the default. Empty for files and stdin.
+func URLHost(path string) string {
+	if IsCalDAV(path) {
+		path = calDAVURL(path)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if u.Port() == "" || u.Port() == defaultPorts[u.Scheme] {
+		return host
+	}
+	return net.JoinHostPort(host, u.Port())
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	return defaultPorts[u.Scheme]
+}
+
+// Netrc entry for the host of a URL, or the default entry
+func (a *HTTPAuth) netrcEntry(rawURL string) *NetrcEntry {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	var fallback *NetrcEntry
+	for i, entry := range a.Netrc {
+		if entry.Machine == "" && fallback == nil {
+			fallback = &a.Netrc[i]
+		}
+		if strings.EqualFold(entry.Machine, u.Hostname()) {
+			return &a.Netrc[i]
+		}
+	}
+	return fallback
+}
+
+func basicAuth(username string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// Parse a 'Name: value' header
+func ParseHeader(header string) (string, string, error) {
+	name, value, ok := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		// The value may be a secret, so is never included in errors
+		return "", "", errors.New("invalid header, use the format 'Name: value'")
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// Read a netrc-style file ('machine <host> login <user> password <secret>')
+func ParseNetrc(path string) ([]NetrcEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, strings.Fields(line)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var entries []NetrcEntry
+	var entry *NetrcEntry
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine", "default":
+			entries = append(entries, NetrcEntry{})
+			entry = &entries[len(entries)-1]
+			if tokens[i] == "machine" {
+				if i+1 >= len(tokens) {
+					return nil, fmt.Errorf("%s: missing machine name", path)
+				}
+				i++
+				entry.Machine = tokens[i]
+			}
+		case "login", "password", "account":
+			if entry == nil || i+1 >= len(tokens) {
+				return nil, fmt.Errorf("%s: '%s' must follow a machine", path, tokens[i])
+			}
+			i++
+			if tokens[i-1] == "login" {
+				entry.Login = tokens[i]
+			} else if tokens[i-1] == "password" {
+				entry.Password = tokens[i]
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// URL with any password replaced by 'xxxxx', safe to print
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+var textURLRegex = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s'"<>]+`)
+
+// Text (e.g. an error message) with the password of every URL in it
+// replaced by 'xxxxx'
+func RedactText(text string) string {
+	return textURLRegex.ReplaceAllStringFunc(text, RedactURL)
+}
+
+// Error whose message has the passwords of URLs redacted, which still
+// unwraps to the original error
+type RedactedError struct {
+	Err error
+}
+
+func (e *RedactedError) Error() string {
+	return RedactText(e.Err.Error())
+}
+
+func (e *RedactedError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap an error so its message is safe to print, nil stays nil
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RedactedError{Err: err}
+}
@@ -0,0 +1,172 @@
+package parse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// Used when no cache TTL is chosen
+const DefaultCacheTTL = 15 * time.Minute
+
+// On-disk cache of fetched URLs.
+//
+// Each URL is stored as its response body, plus a metadata file with
+// the validators (ETag, Last-Modified) used to revalidate it. Responses
+// are cached per URL and credentials, so a feed fetched with one set of
+// credentials is never returned for another (or for none).
+type HTTPCache struct {
+	Dir string
+
+	// How long a cached response is used without revalidating it
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	URL        string
+	Validators HTTPValidators
+	FetchedAt  time.Time
+}
+
+// Cache in the user cache directory (e.g. '~/.cache/ics-to-markdown')
+func NewUserHTTPCache(ttl time.Duration) (*HTTPCache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPCache{Dir: filepath.Join(dir, "ics-to-markdown"), TTL: ttl}, nil
+}
+
+// Fetch a URL through the cache.
+//
+// Fresh responses are used as-is, stale ones are revalidated
+// with a conditional request.
+func (c *HTTPCache) Fetch(url string, opts FetchOptions) ([]byte, error) {
+	key := cacheKey(url, opts)
+	entry, data, err := c.load(key)
+	if err == nil && time.Since(entry.FetchedAt) < c.TTL {
+		return data, nil
+	}
+
+	validators := HTTPValidators{}
+	if err == nil {
+		validators = entry.Validators
+	}
+
+	fetched, validators, err := FetchUrlIfModified(url, validators, opts)
+	if errors.Is(err, ErrNotModified) {
+		fetched = data
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Failing to cache should not fail the fetch
+	c.store(key, url, fetched, validators)
+
+	return fetched, nil
+}
+
+// Cached response for a URL (fetched with the credentials of
+// 'opts'), regardless of its age
+func (c *HTTPCache) Cached(url string, opts FetchOptions) ([]byte, error) {
+	_, data, err := c.load(cacheKey(url, opts))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("'%s' has not been cached yet", RedactURL(url))
+	}
+	return data, err
+}
+
+// URL and the credentials and headers sent with it, only its
+// hash is stored
+func cacheKey(url string, opts FetchOptions) string {
+	if opts.Auth == nil {
+		return url
+	}
+
+	header := opts.Auth.header(url)
+	names := lo.Keys(header)
+	sort.Strings(names)
+
+	key := url
+	for _, name := range names {
+		key += "\n" + name + ": " + header[name]
+	}
+	return key
+}
+
+// Paths of the body and metadata files for a cache key
+func (c *HTTPCache) paths(key string) (string, string) {
+	hash := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(hash[:])
+	return filepath.Join(c.Dir, name+".ics"), filepath.Join(c.Dir, name+".json")
+}
+
+func (c *HTTPCache) load(key string) (*cacheEntry, []byte, error) {
+	bodyPath, metaPath := c.paths(key)
+
+	meta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(meta, entry); err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry, data, nil
+}
+
+func (c *HTTPCache) store(key string, url string, data []byte, validators HTTPValidators) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(cacheEntry{
+		URL:        RedactURL(url),
+		Validators: validators,
+		FetchedAt:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	bodyPath, metaPath := c.paths(key)
+	if err := writeFileAtomic(bodyPath, data); err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, meta)
+}
+
+// Write to a temporary file then rename it, so concurrent
+// runs never read a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
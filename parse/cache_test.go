@@ -0,0 +1,155 @@
+package parse
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPCacheRevalidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		etag         bool
+		lastModified string
+	}{
+		{"ETag", true, ""},
+		{"Last-Modified", false, "Mon, 02 Sep 2024 09:00:00 GMT"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFeedServer(t, "v1")
+			server.etag, server.lastModified = test.etag, test.lastModified
+
+			// Every fetch is stale, and revalidated
+			cache := &HTTPCache{Dir: t.TempDir()}
+			url := server.URL + "/calendar.ics"
+
+			for i, want := range []string{"v1", "v1"} {
+				data, err := cache.Fetch(url, FetchOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Errorf("fetch %d: got %q, want %q", i+1, data, want)
+				}
+			}
+
+			// Answered from the cache after a 304 Not Modified
+			if requests, notModified := server.counts(); requests != 2 || notModified != 1 {
+				t.Errorf("got %d requests with %d not modified, want 2 with 1", requests, notModified)
+			}
+		})
+	}
+}
+
+func TestHTTPCacheChanged(t *testing.T) {
+	server := newFeedServer(t, "v1")
+	cache := &HTTPCache{Dir: t.TempDir()}
+	url := server.URL + "/calendar.ics"
+
+	if _, err := cache.Fetch(url, FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	server.set("v2")
+
+	data, err := cache.Fetch(url, FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "v2" {
+		t.Errorf("got %q, want the changed feed", data)
+	}
+
+	// The new version replaces the cached one
+	if data, _ := cache.Cached(url, FetchOptions{}); string(data) != "v2" {
+		t.Errorf("got %q cached, want v2", data)
+	}
+}
+
+func TestHTTPCacheTTL(t *testing.T) {
+	server := newFeedServer(t, "v1")
+	cache := &HTTPCache{Dir: t.TempDir(), TTL: time.Hour}
+	url := server.URL + "/calendar.ics"
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Fetch(url, FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests, _ := server.counts(); requests != 1 {
+		t.Errorf("got %d requests, want 1 while the cached copy is fresh", requests)
+	}
+}
+
+func TestHTTPCacheOffline(t *testing.T) {
+	server := newFeedServer(t, "v1")
+	cache := &HTTPCache{Dir: t.TempDir(), TTL: time.Hour}
+	cached := server.URL + "/calendar.ics"
+	missing := server.URL + "/other.ics"
+
+	if _, err := cache.Fetch(cached, FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	results := FetchICSAll([]string{cached, missing}, FetchOptions{Cache: cache, Offline: true})
+
+	if results[0].Err != nil || string(results[0].Data) != "v1" {
+		t.Errorf("got %q, %v offline, want the cached feed", results[0].Data, results[0].Err)
+	}
+	if want := "'" + missing + "' has not been cached yet"; results[1].Err == nil || results[1].Err.Error() != want {
+		t.Errorf("got error %v offline, want %q", results[1].Err, want)
+	}
+}
+
+// Responses are never shared between credentials
+func TestHTTPCacheCredentials(t *testing.T) {
+	server := newFeedServer(t, "private")
+	cache := &HTTPCache{Dir: t.TempDir(), TTL: time.Hour}
+	url := server.URL + "/calendar.ics"
+
+	alice := FetchOptions{Auth: &HTTPAuth{Username: "alice", Password: "s3cret"}}
+	if _, err := cache.Fetch(url, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   FetchOptions
+		cached bool
+	}{
+		{"same credentials", FetchOptions{Auth: &HTTPAuth{Username: "alice", Password: "s3cret"}}, true},
+		{"other password", FetchOptions{Auth: &HTTPAuth{Username: "alice", Password: "guess"}}, false},
+		{"other user", FetchOptions{Auth: &HTTPAuth{Username: "bob", Password: "s3cret"}}, false},
+		{"bearer token", FetchOptions{Auth: &HTTPAuth{BearerToken: "s3cret"}}, false},
+		{"anonymous", FetchOptions{}, false},
+		{"credentials for another host", FetchOptions{Auth: &HTTPAuth{Host: "example.com", Username: "alice", Password: "s3cret"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := cache.Cached(url, test.opts)
+			if cached := err == nil; cached != test.cached {
+				t.Errorf("got cached %v (%v), want %v", cached, err, test.cached)
+			}
+		})
+	}
+
+	// The credentials are not written to the cache
+	entries, err := os.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(cache.Dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), basicAuth("alice", "s3cret")) {
+			t.Errorf("%s contains the credentials", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package parse
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/imroc/req"
+)
+
+// CalDAV sources, e.g. 'caldavs://cloud.example.com/remote.php/dav/calendars/alice/'.
+//
+// 'caldav://' is fetched over http, 'caldavs://' over https.
+func IsCalDAV(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "caldav://") || strings.HasPrefix(lower, "caldavs://")
+}
+
+// HTTP URL of a CalDAV source
+func calDAVURL(path string) string {
+	if strings.HasPrefix(strings.ToLower(path), "caldavs://") {
+		return "https://" + path[len("caldavs://"):]
+	}
+	return "http://" + path[len("caldav://"):]
+}
+
+// Calendar collection found on a CalDAV server
+type calDAVCalendar struct {
+	URL  string
+	Name string
+}
+
+// Fetch the events of a CalDAV source as a single ICS calendar.
+//
+// The source can be a calendar, a calendar home (all calendars of a
+// user are merged) or a principal/server URL, which are discovered with
+// PROPFIND. Only events in the opts.Start/opts.End window are requested.
+func FetchCalDAV(path string, opts FetchOptions) ([]byte, error) {
+	baseURL := calDAVURL(path)
+
+	calendars, err := discoverCalDAVCalendars(baseURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(calendars) == 0 {
+		return nil, fmt.Errorf("no calendars found at '%s'", RedactURL(baseURL))
+	}
+
+	merged := ics.NewCalendar()
+	timezones := make(map[string]bool)
+	var names []string
+
+	for _, calendar := range calendars {
+		objects, err := queryCalDAVEvents(calendar.URL, opts)
+		if err != nil {
+			return nil, err
+		}
+		if calendar.Name != "" {
+			names = append(names, calendar.Name)
+		}
+
+		for _, object := range objects {
+			parsed, err := ics.ParseCalendar(strings.NewReader(object))
+			if err != nil {
+				return nil, fmt.Errorf("invalid calendar data from '%s': %v", RedactURL(calendar.URL), err)
+			}
+
+			for _, component := range parsed.Components {
+				// Every object carries the timezones it uses, keep one of each
+				if timezone, ok := component.(*ics.VTimezone); ok {
+					if tzid := timezone.GetProperty(ics.ComponentPropertyTzid); tzid != nil {
+						if timezones[tzid.Value] {
+							continue
+						}
+						timezones[tzid.Value] = true
+					}
+				}
+				merged.Components = append(merged.Components, component)
+			}
+		}
+	}
+
+	if len(names) > 0 {
+		merged.SetXWRCalName(strings.Join(names, ", "))
+	}
+
+	return []byte(merged.Serialize()), nil
+}
+
+// Calendars at a URL, following the principal to its calendar home
+// when the URL does not contain calendars itself
+func discoverCalDAVCalendars(baseURL string, opts FetchOptions) ([]calDAVCalendar, error) {
+	calendars, err := findCalDAVCalendars(baseURL, opts)
+	if err != nil || len(calendars) > 0 {
+		return calendars, err
+	}
+
+	home, err := findCalDAVHome(baseURL, opts)
+	if err != nil || home == "" {
+		return nil, err
+	}
+	return findCalDAVCalendars(home, opts)
+}
+
+// Calendar collections at a URL, or only the URL if it is a calendar
+func findCalDAVCalendars(collectionURL string, opts FetchOptions) ([]calDAVCalendar, error) {
+	multistatus, err := davRequest("PROPFIND", collectionURL, "1", propfindCalendarsBody, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []calDAVCalendar
+	for _, response := range multistatus.Responses {
+		prop := response.prop()
+		if prop.ResourceType.Calendar == nil {
+			continue
+		}
+
+		calendarURL, err := resolveHref(collectionURL, response.Href)
+		if err != nil {
+			return nil, err
+		}
+
+		calendar := calDAVCalendar{URL: calendarURL, Name: strings.TrimSpace(prop.DisplayName)}
+		if sameCollection(calendarURL, collectionURL) {
+			return []calDAVCalendar{calendar}, nil
+		}
+		calendars = append(calendars, calendar)
+	}
+
+	return calendars, nil
+}
+
+// Calendar home of the user at a URL, through their principal if needed
+func findCalDAVHome(baseURL string, opts FetchOptions) (string, error) {
+	multistatus, err := davRequest("PROPFIND", baseURL, "0", propfindHomeBody, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var principal string
+	for _, response := range multistatus.Responses {
+		prop := response.prop()
+		if prop.CalendarHomeSet.Href != "" {
+			return resolveHref(baseURL, prop.CalendarHomeSet.Href)
+		}
+		if prop.CurrentUserPrincipal.Href != "" {
+			principal = prop.CurrentUserPrincipal.Href
+		}
+	}
+
+	if principal == "" {
+		return "", nil
+	}
+
+	principalURL, err := resolveHref(baseURL, principal)
+	if err != nil || sameCollection(principalURL, baseURL) {
+		return "", err
+	}
+	return findCalDAVHome(principalURL, opts)
+}
+
+// Calendar data of the events in a calendar, limited to the fetch window
+func queryCalDAVEvents(calendarURL string, opts FetchOptions) ([]string, error) {
+	timeRange := ""
+	if !opts.Start.IsZero() || !opts.End.IsZero() {
+		timeRange = "<c:time-range"
+		if !opts.Start.IsZero() {
+			timeRange += ` start="` + opts.Start.UTC().Format(calDAVTimeFormat) + `"`
+		}
+		if !opts.End.IsZero() {
+			timeRange += ` end="` + opts.End.UTC().Format(calDAVTimeFormat) + `"`
+		}
+		timeRange += "/>"
+	}
+
+	multistatus, err := davRequest("REPORT", calendarURL, "1", fmt.Sprintf(calendarQueryBody, timeRange), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []string
+	for _, response := range multistatus.Responses {
+		if data := strings.TrimSpace(response.prop().CalendarData); data != "" {
+			objects = append(objects, data)
+		}
+	}
+	return objects, nil
+}
+
+// Send a WebDAV request and parse its multistatus response
+func davRequest(method string, davURL string, depth string, body string, opts FetchOptions) (*davMultistatus, error) {
+	header := req.Header{}
+	if opts.Auth != nil {
+		header = opts.Auth.header(davURL)
+	}
+	header["Depth"] = depth
+	header["Content-Type"] = "application/xml; charset=utf-8"
+
+	_, data, err := doWithRetries(method, davURL, header, body, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	multistatus := &davMultistatus{}
+	if err := xml.Unmarshal(data, multistatus); err != nil {
+		return nil, fmt.Errorf("invalid CalDAV response from '%s': %v", RedactURL(davURL), err)
+	}
+	return multistatus, nil
+}
+
+// Absolute URL of an href in a response
+func resolveHref(baseURL string, href string) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// Checks if two URLs are the same collection, ignoring a trailing slash
+func sameCollection(a string, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host) && strings.TrimSuffix(ua.EscapedPath(), "/") == strings.TrimSuffix(ub.EscapedPath(), "/")
+}
+
+// UTC date-time of CalDAV time ranges
+const calDAVTimeFormat = "20060102T150405Z"
+
+const propfindCalendarsBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:resourcetype/>
+    <d:displayname/>
+  </d:prop>
+</d:propfind>`
+
+const propfindHomeBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:current-user-principal/>
+    <c:calendar-home-set/>
+  </d:prop>
+</d:propfind>`
+
+// Takes the time-range element, empty for all events
+const calendarQueryBody = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <c:calendar-data/>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">%s</c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Status string  `xml:"DAV: status"`
+	Prop   davProp `xml:"DAV: prop"`
+}
+
+type davProp struct {
+	ResourceType struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	DisplayName          string  `xml:"DAV: displayname"`
+	CurrentUserPrincipal davHref `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      davHref `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	CalendarData         string  `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+// Properties found in a response, ignoring those the server
+// reported as missing (e.g. '404 Not Found')
+func (r davResponse) prop() davProp {
+	var prop davProp
+	for _, propstat := range r.Propstats {
+		if propstat.Status != "" && !strings.Contains(propstat.Status, " 200 ") {
+			continue
+		}
+		if propstat.Prop.ResourceType.Calendar != nil {
+			prop.ResourceType = propstat.Prop.ResourceType
+		}
+		prop.DisplayName += propstat.Prop.DisplayName
+		prop.CalendarData += propstat.Prop.CalendarData
+		if propstat.Prop.CurrentUserPrincipal.Href != "" {
+			prop.CurrentUserPrincipal = propstat.Prop.CurrentUserPrincipal
+		}
+		if propstat.Prop.CalendarHomeSet.Href != "" {
+			prop.CalendarHomeSet = propstat.Prop.CalendarHomeSet
+		}
+	}
+	return prop
+}
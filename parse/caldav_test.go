@@ -0,0 +1,266 @@
+package parse
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// Request received by the test CalDAV server
+type davTestRequest struct {
+	Method string
+	Path   string
+	Depth  string
+	Body   string
+}
+
+// CalDAV server answering requests from a table of
+// 'METHOD path' to multistatus bodies, recording every request
+type davTestServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []davTestRequest
+}
+
+func newDAVTestServer(t *testing.T, responses map[string]string) *davTestServer {
+	t.Helper()
+
+	server := &davTestServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.mu.Lock()
+		server.requests = append(server.requests, davTestRequest{Method: r.Method, Path: r.URL.Path, Depth: r.Header.Get("Depth"), Body: string(body)})
+		server.mu.Unlock()
+
+		response, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`+response+`</d:multistatus>`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// 'caldav://' URL of a path on the server
+func (s *davTestServer) calDAVURL(path string) string {
+	return "caldav://" + strings.TrimPrefix(s.URL, "http://") + path
+}
+
+// Names ('namespace local') of every element in an XML body
+func xmlElements(t *testing.T, body string) []string {
+	t.Helper()
+
+	var names []string
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatalf("invalid request body: %v\n%s", err, body)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			names = append(names, start.Name.Space+" "+start.Name.Local)
+		}
+	}
+}
+
+// Attributes of the time-range element in a calendar-query body,
+// nil when it has none
+func timeRangeAttrs(t *testing.T, body string) map[string]string {
+	t.Helper()
+
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("invalid request body: %v\n%s", err, body)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name == (xml.Name{Space: "urn:ietf:params:xml:ns:caldav", Local: "time-range"}) {
+			attrs := make(map[string]string)
+			for _, attr := range start.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			return attrs
+		}
+	}
+}
+
+func davCalendarObject(uid string, summary string, start string) string {
+	return "<d:response><d:href>/calendars/alice/" + uid + ".ics</d:href><d:propstat><d:prop><c:calendar-data>" +
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nBEGIN:STANDARD\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nDTSTART:19701025T030000\r\nRRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\r\nEND:STANDARD\r\n" +
+		"BEGIN:DAYLIGHT\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nDTSTART:19700329T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\nEND:DAYLIGHT\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\nUID:" + uid + "\r\nSUMMARY:" + summary + "\r\nDTSTART;TZID=Europe/Berlin:" + start + "\r\nDTEND;TZID=Europe/Berlin:" + start[:9] + "235900\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>"
+}
+
+func TestFetchCalDAVDiscovery(t *testing.T) {
+	server := newDAVTestServer(t, map[string]string{
+		// Server root, without calendars, pointing to the principal
+		"PROPFIND /": `<d:response><d:href>/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype>` +
+			`<d:current-user-principal><d:href>/principals/alice/</d:href></d:current-user-principal></d:prop>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+		"PROPFIND /principals/alice/": `<d:response><d:href>/principals/alice/</d:href><d:propstat><d:prop>` +
+			`<c:calendar-home-set><d:href>/calendars/alice/</d:href></c:calendar-home-set></d:prop>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+		"PROPFIND /calendars/alice/": `<d:response><d:href>/calendars/alice/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>` +
+			`<d:response><d:href>/calendars/alice/work/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/><c:calendar/></d:resourcetype>` +
+			`<d:displayname>Work</d:displayname></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>` +
+			`<d:response><d:href>http://other.invalid/calendars/alice/inbox/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>` +
+			`<d:response><d:href>/calendars/alice/home/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/><c:calendar/></d:resourcetype></d:prop>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat><d:propstat><d:prop><d:displayname>Ignored</d:displayname></d:prop>` +
+			`<d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>`,
+		"REPORT /calendars/alice/work/": davCalendarObject("standup", "Standup", "20240902T090000") + davCalendarObject("review", "Review", "20240903T140000"),
+		"REPORT /calendars/alice/home/": davCalendarObject("dentist", "Dentist", "20240904T080000"),
+	})
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	opts := FetchOptions{
+		Start:    time.Date(2024, 9, 1, 0, 0, 0, 0, berlin),
+		End:      time.Date(2024, 10, 1, 0, 0, 0, 0, berlin),
+		Attempts: 1,
+	}
+
+	data, err := FetchCalDAV(server.calDAVURL("/"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests follow the chain root -> principal -> calendar home -> calendars
+	want := []struct {
+		method string
+		path   string
+		depth  string
+	}{
+		{"PROPFIND", "/", "1"},
+		{"PROPFIND", "/", "0"},
+		{"PROPFIND", "/principals/alice/", "0"},
+		{"PROPFIND", "/calendars/alice/", "1"},
+		{"REPORT", "/calendars/alice/work/", "1"},
+		{"REPORT", "/calendars/alice/home/", "1"},
+	}
+	if len(server.requests) != len(want) {
+		t.Fatalf("got %d requests %+v, want %d", len(server.requests), server.requests, len(want))
+	}
+
+	for i, request := range server.requests {
+		if request.Method != want[i].method || request.Path != want[i].path || request.Depth != want[i].depth {
+			t.Errorf("request %d is %s %s (Depth %s), want %s %s (Depth %s)", i, request.Method, request.Path, request.Depth, want[i].method, want[i].path, want[i].depth)
+		}
+
+		elements := xmlElements(t, request.Body)
+		var wantElements []string
+		switch {
+		case request.Method == "PROPFIND" && request.Depth == "1":
+			wantElements = []string{"DAV: propfind", "DAV: resourcetype", "DAV: displayname"}
+		case request.Method == "PROPFIND":
+			wantElements = []string{"DAV: propfind", "DAV: current-user-principal", "urn:ietf:params:xml:ns:caldav calendar-home-set"}
+		default:
+			wantElements = []string{"urn:ietf:params:xml:ns:caldav calendar-query", "urn:ietf:params:xml:ns:caldav calendar-data", "urn:ietf:params:xml:ns:caldav comp-filter"}
+		}
+		for _, element := range wantElements {
+			if !slices.Contains(elements, element) {
+				t.Errorf("request %d body has no %q element:\n%s", i, element, request.Body)
+			}
+		}
+	}
+
+	// Calendar queries are limited to the window, in UTC
+	for _, request := range server.requests[4:] {
+		attrs := timeRangeAttrs(t, request.Body)
+		if attrs["start"] != "20240831T220000Z" || attrs["end"] != "20240930T220000Z" {
+			t.Errorf("REPORT %s has time-range %v, want 20240831T220000Z to 20240930T220000Z", request.Path, attrs)
+		}
+	}
+
+	calendar, err := ics.ParseCalendar(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uids []string
+	for _, event := range calendar.Events() {
+		uids = append(uids, event.Id())
+	}
+	if got := strings.Join(uids, ","); got != "standup,review,dentist" {
+		t.Errorf("got events %s, want standup,review,dentist", got)
+	}
+	if got := len(calendar.Timezones()); got != 1 {
+		t.Errorf("got %d timezones, want the shared one once", got)
+	}
+
+	// Names of missing properties ('404 Not Found') are ignored
+	if !strings.Contains(string(data), "X-WR-CALNAME:Work\r\n") {
+		t.Errorf("got calendar without the name 'Work':\n%s", data)
+	}
+}
+
+func TestFetchCalDAVCalendar(t *testing.T) {
+	server := newDAVTestServer(t, map[string]string{
+		"PROPFIND /calendars/alice/work/": `<d:response><d:href>/calendars/alice/work/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/><c:calendar/></d:resourcetype>` +
+			`<d:displayname>Work</d:displayname></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>` +
+			`<d:response><d:href>/calendars/alice/work/standup.ics</d:href><d:propstat><d:prop><d:resourcetype/></d:prop>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+		"REPORT /calendars/alice/work/": davCalendarObject("standup", "Standup", "20240902T090000"),
+	})
+
+	// Without a window, every event is requested
+	if _, err := FetchCalDAV(server.calDAVURL("/calendars/alice/work/"), FetchOptions{Attempts: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.requests) != 2 {
+		t.Fatalf("got %d requests %+v, want PROPFIND and REPORT", len(server.requests), server.requests)
+	}
+	report := server.requests[1]
+	if report.Method != "REPORT" || report.Path != "/calendars/alice/work/" || report.Depth != "1" {
+		t.Errorf("got %s %s (Depth %s), want REPORT /calendars/alice/work/ (Depth 1)", report.Method, report.Path, report.Depth)
+	}
+	if attrs := timeRangeAttrs(t, report.Body); attrs != nil {
+		t.Errorf("got time-range %v, want none", attrs)
+	}
+}
+
+func TestFetchCalDAVErrors(t *testing.T) {
+	server := newDAVTestServer(t, map[string]string{
+		// Neither calendars nor a principal
+		"PROPFIND /empty/": `<d:response><d:href>/empty/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop>` +
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/empty/", "no calendars found"},
+		{"/missing/", "404"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			_, err := FetchCalDAV(server.calDAVURL(test.path), FetchOptions{Attempts: 1})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts accepted for dates, RFC 3339 timestamps carry their own offset
+var dateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+var (
+	dateOffsetRegex  = regexp.MustCompile(`^([+-]\d+)([dwmy])$`)
+	dateAgoRegex     = regexp.MustCompile(`^(\d+)([dwmy])[ -]ago$`)
+	dateDayRegex     = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	dateMonthRegex   = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+	dateQuarterRegex = regexp.MustCompile(`^(\d{4})-q([1-4])$`)
+	dateWeekRegex    = regexp.MustCompile(`^(\d{4})-w(\d{2})$`)
+	datePeriodRegex  = regexp.MustCompile(`^(last|this|next)[ -](week|month|quarter|year)$`)
+	dateWeekdayRegex = regexp.MustCompile(`^(last|this|next)[ -](monday|tuesday|wednesday|thursday|friday|saturday|sunday)$`)
+)
+
+// Period of time named by a date expression, from Start up to
+// (not including) End
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Parse a date expression into the instant it starts at.
+//
+// See ParseDateRange for the accepted expressions, e.g. 'tomorrow' is
+// midnight at the start of tomorrow, and '+7d' a week from today.
+func ParseDateTime(value string, now time.Time) (time.Time, error) {
+	if day, ok := dateOffset(value, now); ok {
+		return day, nil
+	}
+
+	dateRange, err := ParseDateRange(value, now)
+	return dateRange.Start, err
+}
+
+// Parse a date expression into the period it names, resolved against 'now'
+// (and in its timezone, unless the expression has an offset).
+//
+// Accepts:
+//   - dates and times: '2024-09-01', '2024-09-01T14:30', RFC 3339 timestamps
+//   - months, quarters and ISO weeks: '2024-09', '2024-Q3', '2024-W33'
+//   - 'yesterday', 'today', 'tomorrow'
+//   - '(last|this|next)-(week|month|quarter|year)', weeks start on Monday
+//   - 'next monday' (the first after today), 'last friday' (the last
+//     before today) and 'this sunday' (in this week)
+//   - days, weeks, months or years from today: '+7d', '-2w', '+1m', '-1y',
+//     the period is between today and that day. '2w ago' is the same as
+//     '-2w', without the leading '-' which reads as a flag on the command line
+func ParseDateRange(value string, now time.Time) (DateRange, error) {
+	invalid := fmt.Errorf("invalid date '%s'", value)
+
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(strings.TrimSpace(value)), now.Location()); err == nil {
+			return DateRange{Start: t, End: t}, nil
+		}
+	}
+
+	today := startOfDay(now)
+	if day, ok := dateOffset(value, now); ok {
+		if day.Before(today) {
+			return DateRange{Start: day, End: today}, nil
+		}
+		return DateRange{Start: today, End: day}, nil
+	}
+
+	value = strings.ToLower(strings.TrimSpace(value))
+	loc := now.Location()
+
+	switch value {
+	case "yesterday":
+		return dayRange(today.AddDate(0, 0, -1)), nil
+	case "today":
+		return dayRange(today), nil
+	case "tomorrow":
+		return dayRange(today.AddDate(0, 0, 1)), nil
+	}
+
+	if matched := datePeriodRegex.FindStringSubmatch(value); matched != nil {
+		n := map[string]int{"last": -1, "this": 0, "next": 1}[matched[1]]
+
+		switch matched[2] {
+		case "week":
+			start := startOfWeek(today).AddDate(0, 0, 7*n)
+			return DateRange{Start: start, End: start.AddDate(0, 0, 7)}, nil
+		case "month":
+			start := time.Date(today.Year(), today.Month()+time.Month(n), 1, 0, 0, 0, 0, loc)
+			return DateRange{Start: start, End: start.AddDate(0, 1, 0)}, nil
+		case "quarter":
+			quarterMonth := (today.Month()-1)/3*3 + 1
+			start := time.Date(today.Year(), quarterMonth+time.Month(3*n), 1, 0, 0, 0, 0, loc)
+			return DateRange{Start: start, End: start.AddDate(0, 3, 0)}, nil
+		case "year":
+			start := time.Date(today.Year()+n, 1, 1, 0, 0, 0, 0, loc)
+			return DateRange{Start: start, End: start.AddDate(1, 0, 0)}, nil
+		}
+	}
+
+	if matched := dateWeekdayRegex.FindStringSubmatch(value); matched != nil {
+		weekday := weekdays[strings.ToUpper(matched[2][:2])]
+
+		switch matched[1] {
+		case "last":
+			days := (int(today.Weekday())-int(weekday)+6)%7 + 1
+			return dayRange(today.AddDate(0, 0, -days)), nil
+		case "next":
+			days := (int(weekday)-int(today.Weekday())+6)%7 + 1
+			return dayRange(today.AddDate(0, 0, days)), nil
+		}
+		return dayRange(startOfWeek(today).AddDate(0, 0, (int(weekday)+6)%7)), nil
+	}
+
+	if matched := dateDayRegex.FindStringSubmatch(value); matched != nil {
+		year, month, day := atoi(matched[1]), atoi(matched[2]), atoi(matched[3])
+		start := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+		if start.Month() != time.Month(month) || start.Day() != day {
+			return DateRange{}, invalid
+		}
+		return dayRange(start), nil
+	}
+
+	if matched := dateMonthRegex.FindStringSubmatch(value); matched != nil {
+		year, month := atoi(matched[1]), atoi(matched[2])
+		if month < 1 || month > 12 {
+			return DateRange{}, invalid
+		}
+		start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, loc)
+		return DateRange{Start: start, End: start.AddDate(0, 1, 0)}, nil
+	}
+
+	if matched := dateQuarterRegex.FindStringSubmatch(value); matched != nil {
+		year, quarter := atoi(matched[1]), atoi(matched[2])
+		start := time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, loc)
+		return DateRange{Start: start, End: start.AddDate(0, 3, 0)}, nil
+	}
+
+	if matched := dateWeekRegex.FindStringSubmatch(value); matched != nil {
+		year, week := atoi(matched[1]), atoi(matched[2])
+
+		// The 4th of January is always in the first ISO week
+		start := startOfWeek(time.Date(year, 1, 4, 0, 0, 0, 0, loc)).AddDate(0, 0, 7*(week-1))
+		if isoYear, isoWeek := start.ISOWeek(); week < 1 || isoYear != year || isoWeek != week {
+			return DateRange{}, invalid
+		}
+		return DateRange{Start: start, End: start.AddDate(0, 0, 7)}, nil
+	}
+
+	return DateRange{}, invalid
+}
+
+// Day an offset from today ('+7d', '-2w', '+1m', '-1y', '2w ago') falls on
+func dateOffset(value string, now time.Time) (time.Time, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	matched := dateOffsetRegex.FindStringSubmatch(value)
+	if matched == nil {
+		matched = dateAgoRegex.FindStringSubmatch(value)
+		if matched == nil {
+			return time.Time{}, false
+		}
+		matched[1] = "-" + matched[1]
+	}
+
+	n := atoi(matched[1])
+	today := startOfDay(now)
+
+	switch matched[2] {
+	case "w":
+		return today.AddDate(0, 0, 7*n), true
+	case "m":
+		return today.AddDate(0, n, 0), true
+	case "y":
+		return today.AddDate(n, 0, 0), true
+	}
+	return today.AddDate(0, 0, n), true
+}
+
+func dayRange(day time.Time) DateRange {
+	return DateRange{Start: day, End: day.AddDate(0, 0, 1)}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Monday of the week 't' is in
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// Digits matched by a regex, which always convert
+func atoi(digits string) int {
+	n, _ := strconv.Atoi(digits)
+	return n
+}
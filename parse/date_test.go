@@ -0,0 +1,198 @@
+package parse
+
+import (
+	"testing"
+	"time"
+)
+
+const dateTestLayout = "2006-01-02T15:04:05Z07:00"
+
+// Wednesday of ISO week 37, in the afternoon
+func testNow(t *testing.T) time.Time {
+	t.Helper()
+	return time.Date(2024, 9, 11, 14, 30, 0, 0, mustLoadLocation(t, "Europe/Berlin"))
+}
+
+func TestParseDateRange(t *testing.T) {
+	tests := []struct {
+		value string
+		start string
+		end   string
+	}{
+		{"today", "2024-09-11T00:00:00+02:00", "2024-09-12T00:00:00+02:00"},
+		{" Today ", "2024-09-11T00:00:00+02:00", "2024-09-12T00:00:00+02:00"},
+		{"yesterday", "2024-09-10T00:00:00+02:00", "2024-09-11T00:00:00+02:00"},
+		{"tomorrow", "2024-09-12T00:00:00+02:00", "2024-09-13T00:00:00+02:00"},
+
+		// Offsets, between today and that day
+		{"+3d", "2024-09-11T00:00:00+02:00", "2024-09-14T00:00:00+02:00"},
+		{"-2w", "2024-08-28T00:00:00+02:00", "2024-09-11T00:00:00+02:00"},
+		{"+1m", "2024-09-11T00:00:00+02:00", "2024-10-11T00:00:00+02:00"},
+		{"-1y", "2023-09-11T00:00:00+02:00", "2024-09-11T00:00:00+02:00"},
+		{"+0d", "2024-09-11T00:00:00+02:00", "2024-09-11T00:00:00+02:00"},
+		{"2w ago", "2024-08-28T00:00:00+02:00", "2024-09-11T00:00:00+02:00"},
+		{"3D-ago", "2024-09-08T00:00:00+02:00", "2024-09-11T00:00:00+02:00"},
+
+		// Weekdays
+		{"next monday", "2024-09-16T00:00:00+02:00", "2024-09-17T00:00:00+02:00"},
+		{"next-friday", "2024-09-13T00:00:00+02:00", "2024-09-14T00:00:00+02:00"},
+		{"next wednesday", "2024-09-18T00:00:00+02:00", "2024-09-19T00:00:00+02:00"},
+		{"last monday", "2024-09-09T00:00:00+02:00", "2024-09-10T00:00:00+02:00"},
+		{"last wednesday", "2024-09-04T00:00:00+02:00", "2024-09-05T00:00:00+02:00"},
+		{"last thursday", "2024-09-05T00:00:00+02:00", "2024-09-06T00:00:00+02:00"},
+		{"this monday", "2024-09-09T00:00:00+02:00", "2024-09-10T00:00:00+02:00"},
+		{"This Sunday", "2024-09-15T00:00:00+02:00", "2024-09-16T00:00:00+02:00"},
+
+		// Periods, weeks start on Monday
+		{"this-week", "2024-09-09T00:00:00+02:00", "2024-09-16T00:00:00+02:00"},
+		{"last week", "2024-09-02T00:00:00+02:00", "2024-09-09T00:00:00+02:00"},
+		{"next-week", "2024-09-16T00:00:00+02:00", "2024-09-23T00:00:00+02:00"},
+		{"this-month", "2024-09-01T00:00:00+02:00", "2024-10-01T00:00:00+02:00"},
+		{"next-month", "2024-10-01T00:00:00+02:00", "2024-11-01T00:00:00+01:00"},
+		{"last-month", "2024-08-01T00:00:00+02:00", "2024-09-01T00:00:00+02:00"},
+		{"this-quarter", "2024-07-01T00:00:00+02:00", "2024-10-01T00:00:00+02:00"},
+		{"last-quarter", "2024-04-01T00:00:00+02:00", "2024-07-01T00:00:00+02:00"},
+		{"next-quarter", "2024-10-01T00:00:00+02:00", "2025-01-01T00:00:00+01:00"},
+		{"this-year", "2024-01-01T00:00:00+01:00", "2025-01-01T00:00:00+01:00"},
+		{"last-year", "2023-01-01T00:00:00+01:00", "2024-01-01T00:00:00+01:00"},
+
+		// Calendar periods
+		{"2024-09-01", "2024-09-01T00:00:00+02:00", "2024-09-02T00:00:00+02:00"},
+		{"2024-02-29", "2024-02-29T00:00:00+01:00", "2024-03-01T00:00:00+01:00"},
+		{"2024-10-27", "2024-10-27T00:00:00+02:00", "2024-10-28T00:00:00+01:00"},
+		{"2024-09", "2024-09-01T00:00:00+02:00", "2024-10-01T00:00:00+02:00"},
+		{"2024-12", "2024-12-01T00:00:00+01:00", "2025-01-01T00:00:00+01:00"},
+		{"2024-Q3", "2024-07-01T00:00:00+02:00", "2024-10-01T00:00:00+02:00"},
+		{"2024-q1", "2024-01-01T00:00:00+01:00", "2024-04-01T00:00:00+02:00"},
+		{"2024-W37", "2024-09-09T00:00:00+02:00", "2024-09-16T00:00:00+02:00"},
+		{"2024-W01", "2024-01-01T00:00:00+01:00", "2024-01-08T00:00:00+01:00"},
+		{"2025-W01", "2024-12-30T00:00:00+01:00", "2025-01-06T00:00:00+01:00"},
+		{"2020-W53", "2020-12-28T00:00:00+01:00", "2021-01-04T00:00:00+01:00"},
+
+		// Times are instants, RFC 3339 timestamps keep their offset
+		{"2024-09-02T09:00:00+05:30", "2024-09-02T09:00:00+05:30", "2024-09-02T09:00:00+05:30"},
+		{"2024-09-02T09:00:00Z", "2024-09-02T09:00:00Z", "2024-09-02T09:00:00Z"},
+		{"2024-09-02t09:00:00z", "2024-09-02T09:00:00Z", "2024-09-02T09:00:00Z"},
+		{"2024-09-02T14:30", "2024-09-02T14:30:00+02:00", "2024-09-02T14:30:00+02:00"},
+		{"2024-09-02 14:30:15", "2024-09-02T14:30:15+02:00", "2024-09-02T14:30:15+02:00"},
+	}
+
+	now := testNow(t)
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseDateRange(test.value, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start, end := got.Start.Format(dateTestLayout), got.End.Format(dateTestLayout); start != test.start || end != test.end {
+				t.Errorf("got %s to %s, want %s to %s", start, end, test.start, test.end)
+			}
+		})
+	}
+}
+
+// Periods around the end of a year, month lengths and summer time
+func TestParseDateRangeEdges(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	tests := []struct {
+		now   time.Time
+		value string
+		start string
+		end   string
+	}{
+		{time.Date(2024, 12, 31, 23, 0, 0, 0, berlin), "tomorrow", "2025-01-01T00:00:00+01:00", "2025-01-02T00:00:00+01:00"},
+		{time.Date(2024, 12, 31, 23, 0, 0, 0, berlin), "next-month", "2025-01-01T00:00:00+01:00", "2025-02-01T00:00:00+01:00"},
+		{time.Date(2024, 12, 31, 23, 0, 0, 0, berlin), "next-week", "2025-01-06T00:00:00+01:00", "2025-01-13T00:00:00+01:00"},
+		{time.Date(2024, 1, 15, 12, 0, 0, 0, berlin), "last-quarter", "2023-10-01T00:00:00+02:00", "2024-01-01T00:00:00+01:00"},
+		{time.Date(2024, 1, 31, 12, 0, 0, 0, berlin), "+1m", "2024-01-31T00:00:00+01:00", "2024-03-02T00:00:00+01:00"},
+		{time.Date(2024, 10, 26, 12, 0, 0, 0, berlin), "tomorrow", "2024-10-27T00:00:00+02:00", "2024-10-28T00:00:00+01:00"},
+		{time.Date(2024, 9, 15, 12, 0, 0, 0, berlin), "this-week", "2024-09-09T00:00:00+02:00", "2024-09-16T00:00:00+02:00"},
+		{time.Date(2024, 9, 15, 12, 0, 0, 0, berlin), "next sunday", "2024-09-22T00:00:00+02:00", "2024-09-23T00:00:00+02:00"},
+		{time.Date(2024, 9, 16, 0, 0, 0, 0, berlin), "last sunday", "2024-09-15T00:00:00+02:00", "2024-09-16T00:00:00+02:00"},
+		{time.Date(2024, 9, 11, 14, 30, 0, 0, time.UTC), "today", "2024-09-11T00:00:00Z", "2024-09-12T00:00:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.now.Format(time.DateOnly)+" "+test.value, func(t *testing.T) {
+			got, err := ParseDateRange(test.value, test.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start, end := got.Start.Format(dateTestLayout), got.End.Format(dateTestLayout); start != test.start || end != test.end {
+				t.Errorf("got %s to %s, want %s to %s", start, end, test.start, test.end)
+			}
+		})
+	}
+}
+
+func TestParseDateRangeInvalid(t *testing.T) {
+	now := testNow(t)
+
+	for _, value := range []string{
+		"",
+		"someday",
+		"next",
+		"next fortnight",
+		"next-mon",
+		"monday",
+		"+3",
+		"+3x",
+		"3d",
+		"-2w ago",
+		"2023-02-29",
+		"2024-09-31",
+		"2024-13",
+		"2024-00",
+		"2024-Q5",
+		"2024-W00",
+		"2024-W53",
+		"2024-9-1",
+		"2024-09-02T25:00",
+		"2024-09-02T09:00:00+25:00",
+	} {
+		t.Run(value, func(t *testing.T) {
+			_, err := ParseDateRange(value, now)
+			if err == nil {
+				t.Fatalf("got no error, want one")
+			}
+			if want := "invalid date '" + value + "'"; err.Error() != want {
+				t.Errorf("got error %q, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"today", "2024-09-11T00:00:00+02:00"},
+		{"tomorrow", "2024-09-12T00:00:00+02:00"},
+		{"+7d", "2024-09-18T00:00:00+02:00"},
+		{"-2w", "2024-08-28T00:00:00+02:00"},
+		{"2w ago", "2024-08-28T00:00:00+02:00"},
+		{"next monday", "2024-09-16T00:00:00+02:00"},
+		{"this-month", "2024-09-01T00:00:00+02:00"},
+		{"2024-W37", "2024-09-09T00:00:00+02:00"},
+		{"2024-09-02T09:00:00Z", "2024-09-02T09:00:00Z"},
+	}
+
+	now := testNow(t)
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseDateTime(test.value, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format(dateTestLayout) != test.want {
+				t.Errorf("got %s, want %s", got.Format(dateTestLayout), test.want)
+			}
+		})
+	}
+
+	if _, err := ParseDateTime("someday", now); err == nil || err.Error() != "invalid date 'someday'" {
+		t.Errorf("got error %v, want invalid date 'someday'", err)
+	}
+}
@@ -0,0 +1,108 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How duplicate events (e.g. shared invites in merged calendars) are removed
+type DedupeMode string
+
+const (
+	// Keep every event
+	DedupeOff DedupeMode = "off"
+
+	// Events with the same UID and RECURRENCE-ID are duplicates
+	DedupeUID DedupeMode = "uid"
+
+	// As DedupeUID, plus events with the same summary, start and end
+	DedupeFuzzy DedupeMode = "fuzzy"
+)
+
+// Mode used when none is chosen
+const DefaultDedupeMode = DedupeUID
+
+var DedupeModes = []DedupeMode{DedupeOff, DedupeUID, DedupeFuzzy}
+
+func ParseDedupeMode(value string) (DedupeMode, error) {
+	for _, mode := range DedupeModes {
+		if strings.EqualFold(value, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown dedupe mode '%s' (available: off, uid, fuzzy)", value)
+}
+
+// Remove duplicate events, keeping the latest revision of each.
+//
+// Events stay in their original order, with a duplicate taking the
+// place of the first copy when it is a later revision.
+func ICSEventsDedupe(events []ICSEvent, mode DedupeMode) []ICSEvent {
+	switch mode {
+	case DedupeUID:
+		return dedupeBy(events, uidKey)
+	case DedupeFuzzy:
+		return dedupeBy(dedupeBy(events, uidKey), fuzzyKey)
+	}
+	return events
+}
+
+// Remove events with the same key, events with an empty key are kept
+func dedupeBy(events []ICSEvent, key func(event ICSEvent) string) []ICSEvent {
+	deduped := make([]ICSEvent, 0, len(events))
+	seen := make(map[string]int)
+
+	for _, event := range events {
+		k := key(event)
+		if k == "" {
+			deduped = append(deduped, event)
+			continue
+		}
+
+		if i, ok := seen[k]; ok {
+			if isLaterRevision(event, deduped[i]) {
+				deduped[i] = event
+			}
+			continue
+		}
+
+		seen[k] = len(deduped)
+		deduped = append(deduped, event)
+	}
+
+	return deduped
+}
+
+// UID and RECURRENCE-ID of an event
+func uidKey(event ICSEvent) string {
+	if event.UID == "" {
+		return ""
+	}
+	if event.RecurrenceID.IsZero() {
+		return event.UID
+	}
+
+	// Dates are compared by day, the same event may be parsed in a
+	// different timezone by each calendar
+	if event.AllDay {
+		return event.UID + "|" + event.RecurrenceID.Format("20060102")
+	}
+	return fmt.Sprintf("%s|%d", event.UID, event.RecurrenceID.Unix())
+}
+
+// Summary (ignoring case and spacing), start and end of an event
+func fuzzyKey(event ICSEvent) string {
+	summary := strings.ToLower(strings.Join(strings.Fields(event.Summary), " "))
+	if event.AllDay {
+		return fmt.Sprintf("%s|%s|%s", summary, event.Start.Format("20060102"), event.End.Format("20060102"))
+	}
+	return fmt.Sprintf("%s|%d|%d", summary, event.Start.Unix(), event.End.Unix())
+}
+
+// Checks if 'event' is a later revision than 'other', by SEQUENCE then LAST-MODIFIED
+func isLaterRevision(event ICSEvent, other ICSEvent) bool {
+	if event.Sequence != other.Sequence {
+		return event.Sequence > other.Sequence
+	}
+	return event.LastModified.After(other.LastModified)
+}
@@ -0,0 +1,111 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Summary, calendar and sequence of each event, e.g. 'Standup 20240902T090000 Work#1'
+func dedupeSummary(events []ICSEvent) string {
+	var summary []string
+	for _, event := range events {
+		summary = append(summary, fmt.Sprintf("%s %s %s#%d", event.Summary, event.Start.Format(rruleLayout), event.Calendar, event.Sequence))
+	}
+	return strings.Join(summary, ", ")
+}
+
+// The same recurring series, shared by two calendars, collides on
+// UID and RECURRENCE-ID once the calendars are merged
+func TestICSEventsDedupeMergedSeries(t *testing.T) {
+	series := `UID:standup
+		SUMMARY:Standup
+		SEQUENCE:0
+		DTSTART;TZID=Europe/Berlin:20240902T090000
+		DTEND;TZID=Europe/Berlin:20240902T091500
+		RRULE:FREQ=DAILY;COUNT=3`
+	moved := `UID:standup
+		SUMMARY:Standup (moved)
+		SEQUENCE:1
+		RECURRENCE-ID;TZID=Europe/Berlin:20240903T090000
+		DTSTART;TZID=Europe/Berlin:20240903T100000
+		DTEND;TZID=Europe/Berlin:20240903T101500`
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	opts := ICSParseOptions{Timezone: berlin}
+
+	work, err := IcsToCalendar([]byte(testCalendar(series, moved)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	work.Rename("Work")
+
+	// An older copy of the invite, parsed in another timezone
+	personal, err := IcsToCalendar([]byte(testCalendar(series)), ICSParseOptions{Timezone: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	personal.Rename("Personal")
+
+	merged := MergeCalendars([]*ICSCalendar{personal, work})
+	if len(merged.Events) != 6 {
+		t.Fatalf("got %d merged events, want 6", len(merged.Events))
+	}
+
+	got := dedupeSummary(ICSEventsDedupe(merged.Events, DedupeUID))
+	// In UTC, the timezone of the first calendar
+	want := "Standup 20240902T070000 Personal#0, Standup (moved) 20240903T080000 Work#1, Standup 20240904T070000 Personal#0"
+	if got != want {
+		t.Errorf("events\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestICSEventsDedupe(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 9, 2, hour, 0, 0, 0, time.UTC) }
+	modified := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	events := []ICSEvent{
+		{Summary: "Review", UID: "review", Calendar: "Work", Start: at(9), End: at(10)},
+		{Summary: "Review", UID: "review", Calendar: "Home", Start: at(9), End: at(10), Sequence: 2},
+		{Summary: "Review", UID: "review", Calendar: "Team", Start: at(9), End: at(10), Sequence: 1, LastModified: modified},
+		{Summary: "Lunch", Calendar: "Work", Start: at(12), End: at(13)},
+		{Summary: " lunch ", Calendar: "Home", Start: at(12), End: at(13)},
+		{Summary: "Lunch", Calendar: "Team", Start: at(12), End: at(14)},
+		{Summary: "Retro", UID: "retro", Calendar: "Work", Start: at(15), End: at(16)},
+		{Summary: "Retro", UID: "retro-copy", Calendar: "Home", Start: at(15), End: at(16), LastModified: modified},
+	}
+
+	tests := []struct {
+		mode DedupeMode
+		want string
+	}{
+		{DedupeOff, "Work#0 Home#2 Team#1 Work#0 Home#0 Team#0 Work#0 Home#0"},
+		// The highest SEQUENCE wins, events without a UID are kept
+		{DedupeUID, "Home#2 Work#0 Home#0 Team#0 Work#0 Home#0"},
+		// Events without a UID, or with another UID, are matched by
+		// summary, start and end, the latest LAST-MODIFIED wins
+		{DedupeFuzzy, "Home#2 Work#0 Team#0 Home#0"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.mode), func(t *testing.T) {
+			var got []string
+			for _, event := range ICSEventsDedupe(append([]ICSEvent(nil), events...), test.mode) {
+				got = append(got, fmt.Sprintf("%s#%d", event.Calendar, event.Sequence))
+			}
+			if strings.Join(got, " ") != test.want {
+				t.Errorf("got %s, want %s", strings.Join(got, " "), test.want)
+			}
+		})
+	}
+}
+
+func TestParseDedupeMode(t *testing.T) {
+	if mode, err := ParseDedupeMode("Fuzzy"); err != nil || mode != DedupeFuzzy {
+		t.Errorf("got %q, %v, want fuzzy", mode, err)
+	}
+	if _, err := ParseDedupeMode("exact"); err == nil || err.Error() != "unknown dedupe mode 'exact' (available: off, uid, fuzzy)" {
+		t.Errorf("got error %v, want unknown dedupe mode 'exact'", err)
+	}
+}
@@ -0,0 +1,66 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationDaysRegex = regexp.MustCompile(`^([+-]?\d+)([dw])$`)
+
+// Parse a duration string.
+//
+// Accepts anything time.ParseDuration does ('90m', '1h30m'),
+// plus whole days and weeks ('30d', '2w').
+func ParseDuration(value string) (time.Duration, error) {
+	if matched := durationDaysRegex.FindStringSubmatch(value); matched != nil {
+		n, err := strconv.Atoi(matched[1])
+		if err != nil {
+			return 0, err
+		}
+
+		day := 24 * time.Hour
+		if matched[2] == "w" {
+			return time.Duration(n) * 7 * day, nil
+		}
+		return time.Duration(n) * day, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+	return duration, nil
+}
+
+var icsDurationRegex = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// Parse an RFC 5545 DURATION value (e.g. 'PT1H30M', 'P1D', '-P2W')
+func parseICSDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	matched := icsDurationRegex.FindStringSubmatch(value)
+	if matched == nil || strings.Join(matched[2:], "") == "" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	duration := time.Duration(0)
+	for i, unit := range units {
+		if matched[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(matched[i+2])
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(n) * unit
+	}
+
+	if matched[1] == "-" {
+		duration = -duration
+	}
+	return duration, nil
+}
@@ -2,20 +2,82 @@ package parse
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"hmerritt/go-ics-to-markdown/ui"
 
 	"github.com/imroc/req"
+	"github.com/samber/lo"
 )
 
+// Path used to read from stdin
+const StdinPath = "-"
+
+func IsStdin(path string) bool {
+	return path == StdinPath
+}
+
 func IsUrl(path string) bool {
-	return (strings.HasPrefix(path, "www.") || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://"))
+	return (strings.HasPrefix(path, "www.") || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") ||
+		strings.HasPrefix(path, "webcal://") || strings.HasPrefix(path, "webcals://"))
+}
+
+// Schemes of the URLs which can be fetched
+var SupportedSchemes = []string{"http", "https", "webcal", "webcals", "caldav", "caldavs", "file"}
+
+var urlSchemeRegex = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*)://`)
+
+// Convert links from calendar apps into a path that can be fetched.
+//
+// 'webcal://' and 'webcals://' become 'http://' and 'https://',
+// 'file://' URIs (and relative 'file:' URIs, e.g. 'file:calendar.ics')
+// become local paths. URLs with any other scheme are rejected.
+func ResolveSchemes(path string) (string, error) {
+	lower := strings.ToLower(path)
+
+	switch {
+	case strings.HasPrefix(lower, "webcals://"):
+		return "https://" + path[len("webcals://"):], nil
+	case strings.HasPrefix(lower, "webcal://"):
+		return "http://" + path[len("webcal://"):], nil
+	case strings.HasPrefix(lower, "file:"):
+		u, err := url.Parse(path)
+		if err != nil {
+			return "", fmt.Errorf("invalid file URL '%s': %v", path, err)
+		}
+		if u.Opaque != "" {
+			return filepath.FromSlash(u.Opaque), nil
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return "", fmt.Errorf("file URL '%s' is not on this computer", path)
+		}
+
+		// Windows drive letters, e.g. 'file:///C:/calendar.ics'
+		localPath := u.Path
+		if len(localPath) > 2 && localPath[0] == '/' && localPath[2] == ':' {
+			localPath = localPath[1:]
+		}
+		return filepath.FromSlash(localPath), nil
+	}
+
+	if matched := urlSchemeRegex.FindStringSubmatch(path); matched != nil && !lo.Contains(SupportedSchemes, strings.ToLower(matched[1])) {
+		return "", fmt.Errorf("unsupported URL scheme '%s' in '%s' (available: %s)", matched[1], RedactURL(path), strings.Join(SupportedSchemes, ", "))
+	}
+
+	return path, nil
 }
 
 func UseUrl(path string) bool {
-	return (!FileExists(path) && IsUrl(path))
+	return (!FileExists(path) && (IsUrl(path) || IsCalDAV(path)))
 }
 
 func FileExists(path string) bool {
@@ -28,46 +90,163 @@ func FetchFile(filepath string) ([]byte, error) {
 	return data, err
 }
 
+func FetchStdin() ([]byte, error) {
+	return io.ReadAll(os.Stdin)
+}
+
 func FetchUrl(url string) ([]byte, error) {
-	res, err := req.Get(url)
-	if err != nil {
-		return nil, err
+	data, _, err := FetchUrlIfModified(url, HTTPValidators{}, FetchOptions{})
+	return data, err
+}
+
+// ETag and Last-Modified of a response, sent with later requests
+// (If-None-Match, If-Modified-Since) to only fetch changed data
+type HTTPValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// Returned by FetchUrlIfModified when the data has not changed (304)
+var ErrNotModified = errors.New("not modified")
+
+// Fetch a URL, unless it is unchanged since 'validators' were saved.
+//
+// Returns the validators of the response, to use for the next request.
+func FetchUrlIfModified(url string, validators HTTPValidators, opts FetchOptions) ([]byte, HTTPValidators, error) {
+	header := req.Header{}
+	if opts.Auth != nil {
+		header = opts.Auth.header(url)
+	}
+	if validators.ETag != "" {
+		header["If-None-Match"] = validators.ETag
+	}
+	if validators.LastModified != "" {
+		header["If-Modified-Since"] = validators.LastModified
 	}
 
-	resStatus := res.Response().Status
+	response, body, err := doWithRetries(http.MethodGet, url, header, "", opts)
+	if err != nil {
+		return nil, validators, err
+	}
 
-	if !strings.HasPrefix(resStatus, "1") && !strings.HasPrefix(resStatus, "2") {
-		return nil, errors.New("request returned a bad http status code: " + resStatus + ".")
+	if response.StatusCode == http.StatusNotModified {
+		return nil, validators, ErrNotModified
 	}
 
-	return res.Bytes(), nil
+	return body, HTTPValidators{
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}, nil
 }
 
-// Fetch and parse ICS file locally or from a URL
+// Fetch and parse ICS file locally, from a URL or from stdin ('-')
 func FetchICS(path string) ([]byte, error, bool) {
-	var data []byte
-	var err error
-	var isURL = false
+	result := FetchICSAll([]string{path}, FetchOptions{})[0]
+	return result.Data, result.Err, result.IsURL
+}
 
-	// Decide if URL or file
-	if UseUrl(path) {
-		isURL = true
+// Options for fetching URLs
+type FetchOptions struct {
+	// Cache of responses, nil disables caching
+	Cache *HTTPCache
+
+	// Only use cached responses, never the network
+	Offline bool
+
+	// Credentials and extra headers, nil for anonymous requests
+	Auth *HTTPAuth
+
+	// Limits of each request, zero values use the defaults
+	// (DefaultFetchTimeout, DefaultMaxSize, DefaultMaxRedirects)
+	Timeout      time.Duration
+	MaxSize      int64
+	MaxRedirects int
+
+	// Attempts before giving up on timeouts, dropped connections and temporary
+	// (5xx, 429) responses, with exponential backoff between them
+	Attempts     int
+	RetryBackoff time.Duration
+
+	// Time range requested from CalDAV servers, zero values leave it open
+	Start time.Time
+	End   time.Time
+}
+
+// Result of fetching one of many ICS sources
+type FetchResult struct {
+	Path  string
+	Data  []byte
+	Err   error
+	IsURL bool
+}
+
+// Fetch multiple ICS sources concurrently.
+//
+// Results are in the same order as 'paths'.
+func FetchICSAll(paths []string, opts FetchOptions) []FetchResult {
+	results := make([]FetchResult, len(paths))
+
+	if lo.SomeBy(paths, UseUrl) && !opts.Offline {
 		ui.Spinner.Start("", " Fetching URL data...")
+		defer ui.Spinner.Stop()
+	}
 
-		data, err = FetchUrl(path)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+
+			data, err, isURL := fetchSource(path, opts)
+			if isURL {
+				// URL errors can quote the URL, with its credentials
+				err = RedactError(err)
+			}
+			results[i] = FetchResult{Path: path, Data: data, Err: err, IsURL: isURL}
+		}(i, path)
+	}
+	wg.Wait()
 
-		ui.Spinner.Stop()
+	return results
+}
 
-		if err != nil {
-			return nil, err, isURL
+func fetchSource(path string, opts FetchOptions) ([]byte, error, bool) {
+	path, err := ResolveSchemes(path)
+	if err != nil {
+		return nil, err, false
+	}
+
+	// Decide if stdin, URL or file
+	if IsStdin(path) {
+		data, err := FetchStdin()
+		return data, err, false
+	}
+
+	if IsCalDAV(path) {
+		if opts.Offline {
+			return nil, errors.New("can not fetch CalDAV calendars offline"), true
 		}
-	} else {
-		data, err = FetchFile(path)
+		data, err := FetchCalDAV(path, opts)
+		return data, err, true
+	}
 
-		if err != nil {
-			return nil, err, isURL
+	if UseUrl(path) {
+		if opts.Offline {
+			if opts.Cache == nil {
+				return nil, errors.New("can not fetch URLs offline without a cache"), true
+			}
+			data, err := opts.Cache.Cached(path, opts)
+			return data, err, true
+		}
+		if opts.Cache != nil {
+			data, err := opts.Cache.Fetch(path, opts)
+			return data, err, true
 		}
+
+		data, _, err := FetchUrlIfModified(path, HTTPValidators{}, opts)
+		return data, err, true
 	}
 
-	return data, nil, isURL
+	data, err := FetchFile(path)
+	return data, err, false
 }
@@ -0,0 +1,232 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/imroc/req"
+)
+
+// Used for FetchOptions left as zero values
+const (
+	DefaultFetchTimeout  = 30 * time.Second
+	DefaultFetchAttempts = 4
+	DefaultMaxSize       = 50 << 20
+	DefaultMaxRedirects  = 10
+	DefaultRetryBackoff  = 500 * time.Millisecond
+)
+
+// Longest wait between retries
+const maxRetryBackoff = 30 * time.Second
+
+// Response with an unsuccessful status code
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return "request returned a bad http status code: " + e.Status + "."
+}
+
+// Server errors and rate limiting may succeed when retried
+func (e *HTTPStatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Response body larger than FetchOptions.MaxSize
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response is larger than the %s limit", FormatSize(e.Limit))
+}
+
+// More redirects than FetchOptions.MaxRedirects
+type TooManyRedirectsError struct {
+	Limit int
+}
+
+func (e *TooManyRedirectsError) Error() string {
+	return fmt.Sprintf("stopped after %d redirects", e.Limit)
+}
+
+// Checks if an error is a request timing out
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (o FetchOptions) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return DefaultFetchTimeout
+}
+
+func (o FetchOptions) attempts() int {
+	if o.Attempts > 0 {
+		return o.Attempts
+	}
+	return DefaultFetchAttempts
+}
+
+func (o FetchOptions) maxSize() int64 {
+	if o.MaxSize > 0 {
+		return o.MaxSize
+	}
+	return DefaultMaxSize
+}
+
+func (o FetchOptions) maxRedirects() int {
+	if o.MaxRedirects > 0 {
+		return o.MaxRedirects
+	}
+	return DefaultMaxRedirects
+}
+
+func (o FetchOptions) client() *http.Client {
+	maxRedirects := o.maxRedirects()
+
+	return &http.Client{
+		Timeout: o.timeout(),
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return &TooManyRedirectsError{Limit: maxRedirects}
+			}
+
+			// Go only drops Authorization on redirects to another host name,
+			// keep credentials from other ports and extra headers out too
+			if o.Auth != nil && !o.Auth.matchesHost(request.URL.String()) {
+				for name := range o.Auth.Headers {
+					request.Header.Del(name)
+				}
+				if o.Auth.Username != "" || o.Auth.BearerToken != "" {
+					request.Header.Del("Authorization")
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// Send a request, retrying with exponential backoff on transient
+// network errors and temporary (5xx, 429) responses
+func doWithRetries(method string, url string, header req.Header, body string, opts FetchOptions) (*http.Response, []byte, error) {
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var response *http.Response
+		var data []byte
+		response, data, err = do(method, url, header, body, opts)
+		if err == nil || attempt >= opts.attempts() || !isRetryable(err) {
+			return response, data, err
+		}
+
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+func do(method string, url string, header req.Header, body string, opts FetchOptions) (*http.Response, []byte, error) {
+	args := []interface{}{header, opts.client()}
+	if body != "" {
+		args = append(args, body)
+	}
+
+	res, err := req.Do(method, url, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response := res.Response()
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified {
+		return response, nil, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response, nil, &HTTPStatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+
+	limit := opts.maxSize()
+	if response.ContentLength > limit {
+		return response, nil, &ResponseTooLargeError{Limit: limit}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, limit+1))
+	if err != nil {
+		return response, nil, err
+	}
+	if int64(len(data)) > limit {
+		return response, nil, &ResponseTooLargeError{Limit: limit}
+	}
+
+	return response, data, nil
+}
+
+// Only transient failures are retried: timeouts, refused or reset
+// connections, responses cut short and temporary (5xx, 429) statuses.
+// Bad URLs, unknown hosts and certificate errors fail straight away.
+func isRetryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	return IsTimeout(err) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+var sizeRegex = regexp.MustCompile(`^(\d+)\s*([KMG]i?B?|B)?$`)
+
+// Parse a size in bytes, optionally with a unit ('500KB', '50MB', '1GB')
+func ParseSize(value string) (int64, error) {
+	matched := sizeRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if matched == nil {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+
+	n, err := strconv.ParseInt(matched[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+
+	switch {
+	case strings.HasPrefix(matched[2], "K"):
+		n <<= 10
+	case strings.HasPrefix(matched[2], "M"):
+		n <<= 20
+	case strings.HasPrefix(matched[2], "G"):
+		n <<= 30
+	}
+	return n, nil
+}
+
+// Format a size in bytes with the largest whole unit, e.g. '50MB'
+func FormatSize(size int64) string {
+	for _, unit := range []struct {
+		suffix string
+		shift  int
+	}{{"GB", 30}, {"MB", 20}, {"KB", 10}} {
+		if size >= 1<<unit.shift && size%(1<<unit.shift) == 0 {
+			return fmt.Sprintf("%d%s", size>>unit.shift, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
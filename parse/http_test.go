@@ -0,0 +1,189 @@
+package parse
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/imroc/req"
+)
+
+func TestIsRetryable(t *testing.T) {
+	dialErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com/calendar.ics", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &HTTPStatusError{StatusCode: 503, Status: "503 Service Unavailable"}, true},
+		{"rate limited", &HTTPStatusError{StatusCode: 429, Status: "429 Too Many Requests"}, true},
+		{"timeout", &url.Error{Op: "Get", URL: "https://example.com/calendar.ics", Err: context.DeadlineExceeded}, true},
+		{"DNS timeout", &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, true},
+		{"connection refused", dialErr(syscall.ECONNREFUSED), true},
+		{"connection reset", dialErr(syscall.ECONNRESET), true},
+		{"cut short", fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), true},
+
+		{"not found", &HTTPStatusError{StatusCode: 404, Status: "404 Not Found"}, false},
+		{"unauthorized", &HTTPStatusError{StatusCode: 401, Status: "401 Unauthorized"}, false},
+		{"unsupported scheme", &url.Error{Op: "Get", URL: "ftp://example.com/calendar.ics", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{"unknown host", &url.Error{Op: "Get", URL: "https://nope.invalid/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}}}, false},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://example.com/", Err: &tlsVerificationError{x509.UnknownAuthorityError{}}}, false},
+		{"wrong host certificate", &url.Error{Op: "Get", URL: "https://example.com/", Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}}, false},
+		{"too large", &ResponseTooLargeError{Limit: DefaultMaxSize}, false},
+		{"too many redirects", &url.Error{Op: "Get", URL: "https://example.com/", Err: &TooManyRedirectsError{Limit: DefaultMaxRedirects}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isRetryable(test.err); got != test.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+// Wraps a certificate error like crypto/tls does
+type tlsVerificationError struct {
+	Err error
+}
+
+func (e *tlsVerificationError) Error() string {
+	return "tls: failed to verify certificate: " + e.Err.Error()
+}
+
+func (e *tlsVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// Options retrying quickly, so tests of retries stay fast
+var retryOptions = FetchOptions{Attempts: 3, RetryBackoff: time.Millisecond}
+
+func TestDoWithRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   func(w http.ResponseWriter, r *http.Request, attempt int32)
+		wantCalls int32
+		wantErr   bool
+	}{
+		{
+			name: "server error then success",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int32) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(testICS))
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "cut short then success",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int32) {
+				if attempt == 1 {
+					w.Header().Set("Content-Length", "1000")
+					w.Write([]byte("BEGIN:VCALENDAR\r\n"))
+					return
+				}
+				w.Write([]byte(testICS))
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "server error every time",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int32) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int32) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				test.handler(w, r, calls.Add(1))
+			}))
+			defer server.Close()
+
+			_, data, err := doWithRetries("GET", server.URL, req.Header{}, "", retryOptions)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && string(data) != testICS {
+				t.Errorf("got body %q, want %q", data, testICS)
+			}
+			if got := calls.Load(); got != test.wantCalls {
+				t.Errorf("got %d requests, want %d", got, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoWithRetriesNotRetried(t *testing.T) {
+	// Certificate signed by an unknown authority, counting the connections
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testICS))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	_, _, err := doWithRetries("GET", server.URL, req.Header{}, "", retryOptions)
+	var unknownAuthority x509.UnknownAuthorityError
+	if !errors.As(err, &unknownAuthority) {
+		t.Fatalf("got error %v, want an unknown authority", err)
+	}
+	if got := connections.Load(); got != 1 {
+		t.Errorf("got %d connections, want 1", got)
+	}
+
+	_, _, err = doWithRetries("GET", "ftp://example.com/calendar.ics", req.Header{}, "", retryOptions)
+	if err == nil || isRetryable(err) {
+		t.Errorf("got error %v, want an error which is not retried", err)
+	}
+}
+
+func TestDoWithRetriesConnectionRefused(t *testing.T) {
+	// Address nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	_, _, err = do("GET", "http://"+address+"/calendar.ics", req.Header{}, "", retryOptions)
+	if err == nil || !isRetryable(err) {
+		t.Errorf("got error %v, want an error which is retried", err)
+	}
+}
@@ -3,9 +3,10 @@ package parse
 import (
 	"fmt"
 	"regexp"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	md "github.com/JohannesKaufmann/html-to-markdown"
 	ics "github.com/arran4/golang-ical"
@@ -18,20 +19,173 @@ type ICSEvent struct {
 	End         time.Time
 	Description string
 	Location    string
+	Status      string
+	Organizer   string
+	Attendees   []string
+	Categories  []string
+	URL         string
+	UID         string
+
+	// Email of each attendee, in the same order as Attendees
+	AttendeeEmails []string
+
+	// Name of the calendar the event came from
+	Calendar string
+
+	// Original start of an instance of a recurring event (zero if not recurring)
+	RecurrenceID time.Time
+
+	// Revision of the event, from SEQUENCE and LAST-MODIFIED
+	Sequence     int
+	LastModified time.Time
+
+	// Start and end are dates (without a time), the end date is exclusive
+	AllDay bool
+}
+
+// Day the event starts on
+func (e ICSEvent) FirstDay() time.Time {
+	return time.Date(e.Start.Year(), e.Start.Month(), e.Start.Day(), 0, 0, 0, 0, e.Start.Location())
+}
+
+// Last day the event takes place on.
+//
+// Events ending at midnight (including all-day events) end on the day before.
+func (e ICSEvent) LastDay() time.Time {
+	if !e.End.After(e.Start) {
+		return e.FirstDay()
+	}
+
+	last := time.Date(e.End.Year(), e.End.Month(), e.End.Day(), 0, 0, 0, 0, e.End.Location())
+	if last.Equal(e.End) {
+		last = last.AddDate(0, 0, -1)
+	}
+	return last
+}
+
+// Number of days the event takes place on
+func (e ICSEvent) Days() int {
+	first, last := e.FirstDay(), e.LastDay()
+	firstUTC := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	lastUTC := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+	return int(lastUTC.Sub(firstUTC).Hours()/24) + 1
+}
+
+func (e ICSEvent) IsMultiDay() bool {
+	return e.Days() > 1
 }
 
 type ICSEventFilter struct {
 	Start time.Time
 	End   time.Time
+
+	// How events are matched against the window (defaults to MatchContained)
+	Match MatchMode
+
+	// Events must match one of Include (unless empty), all of Where,
+	// and none of Exclude
+	Include []TextFilter
+	Where   []TextFilter
+	Exclude []TextFilter
+
+	// Expression events must match, nil for none (see ParseQuery)
+	Query *Query
+}
+
+// Options used when parsing ICS data into events
+type ICSParseOptions struct {
+	// Window used to bound the expansion of recurring events
+	Start time.Time
+	End   time.Time
+
+	// How far recurring events are expanded when no window end (or
+	// start) is given
+	Horizon time.Duration
+
+	// Reference time used when no window is given (defaults to time.Now)
+	Now time.Time
+
+	// Timezone all events are converted to (defaults to the
+	// calendar's X-WR-TIMEZONE, or the local timezone)
+	Timezone *time.Location
+}
+
+// Default expansion horizon for recurring events
+const DefaultHorizon = 365 * 24 * time.Hour
+
+// Limit for series which are expanded in full, they end on their own
+var fullExpansionLimit = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// Window in which recurring events are expanded.
+//
+// Without an end, the window ends a horizon after its start (or now).
+// Without a start, it begins a horizon before now (or the end, if
+// earlier), so a long running series does not output every instance
+// since it began.
+//
+// Series which end (with a COUNT or UNTIL) ignore the window when
+// neither a start nor an end is given, like events which do not recur.
+func (o ICSParseOptions) expansionWindow() (time.Time, time.Time) {
+	horizon := o.Horizon
+	if horizon <= 0 {
+		horizon = DefaultHorizon
+	}
+
+	now := o.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	start, end := o.Start, o.End
+	if end.IsZero() {
+		end = lo.Ternary(start.IsZero(), now, start).Add(horizon)
+	}
+	if start.IsZero() {
+		start = lo.Ternary(!o.End.IsZero() && o.End.Before(now), o.End, now).Add(-horizon)
+	}
+
+	return start, end
 }
 
+// Parsed calendar, with its events and metadata
+type ICSCalendar struct {
+	// X-WR-CALNAME (or NAME) of the calendar
+	Name string
+
+	// X-WR-CALDESC (or DESCRIPTION) of the calendar
+	Description string
+
+	Events []ICSEvent
+
+	// Which values are present in at least one event
+	HasEventValue map[string]bool
+
+	// Timezone the events have been converted to
+	Timezone *time.Location
+}
+
+// Events of the ICS data, parsed with the default options
 func IcsToEvents(icsData []byte) ([]ICSEvent, map[string]bool, error) {
-	calendar, err := ics.ParseCalendar(strings.NewReader(string(icsData)))
+	return IcsToEventsWithOptions(icsData, ICSParseOptions{})
+}
+
+// Events of the ICS data, see IcsToCalendar for the calendar metadata
+func IcsToEventsWithOptions(icsData []byte, opts ICSParseOptions) ([]ICSEvent, map[string]bool, error) {
+	calendar, err := IcsToCalendar(icsData, opts)
 	if err != nil {
 		return nil, nil, err
 	}
+	return calendar.Events, calendar.HasEventValue, nil
+}
+
+func IcsToCalendar(icsData []byte, opts ICSParseOptions) (*ICSCalendar, error) {
+	calendar, err := ics.ParseCalendar(strings.NewReader(string(icsData)))
+	if err != nil {
+		return nil, err
+	}
 
 	htmlToMd := md.NewConverter("", true, nil)
+	tz := newTimezones(calendar)
 
 	hasEventValue := map[string]bool{
 		"start":       true,
@@ -39,130 +193,372 @@ func IcsToEvents(icsData []byte) ([]ICSEvent, map[string]bool, error) {
 		"summary":     false,
 		"description": false,
 		"location":    false,
+		"status":      false,
+		"organizer":   false,
+		"categories":  false,
+		"url":         false,
+		"uid":         false,
+	}
+
+	windowStart, windowEnd := opts.expansionWindow()
+	hasWindow := !opts.Start.IsZero() || !opts.End.IsZero()
+
+	// Split series from the events which override a single instance
+	var series []*ics.VEvent
+	overrides := make(map[string][]*recurrenceOverride)
+	for _, event := range calendar.Events() {
+		if event.GetProperty(ics.ComponentProperty(ics.PropertyRecurrenceId)) == nil {
+			series = append(series, event)
+			continue
+		}
+
+		override, err := newRecurrenceOverride(event, tz, vEventToICSEvent(event, tz, htmlToMd, hasEventValue))
+		if err != nil {
+			return nil, err
+		}
+		overrides[event.Id()] = append(overrides[event.Id()], override)
 	}
 
 	var events []ICSEvent
-	for _, component := range calendar.Components {
-		if event, ok := component.(*ics.VEvent); ok {
-			start, _ := event.GetStartAt()
-			end, _ := event.GetEndAt()
-			summary := ""
-			description := ""
-			location := ""
-
-			if summaryProp := event.GetProperty(ics.ComponentPropertySummary); summaryProp != nil && summaryProp.Value != "" {
-				summary = summaryProp.Value
-				hasEventValue["summary"] = true
+	for _, event := range series {
+		icsEvent := vEventToICSEvent(event, tz, htmlToMd, hasEventValue)
+
+		duration := time.Duration(0)
+		if !icsEvent.End.IsZero() {
+			duration = icsEvent.End.Sub(icsEvent.Start)
+		}
+
+		from, limit := windowStart, windowEnd
+		if !hasWindow && isFiniteSeries(event) {
+			from, limit = time.Time{}, fullExpansionLimit
+		}
+
+		// Instances which start before the window can still end in it
+		recurrences, err := expandRecurrences(event, tz, icsEvent.Start, from.Add(-duration), limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if recurrences == nil {
+			events = append(events, icsEvent)
+			continue
+		}
+
+		for _, r := range recurrences {
+			instance := icsEvent
+			instance.Start = r.Start
+			instance.End = r.Start.Add(duration)
+			if icsEvent.AllDay {
+				instance.End = r.Start.AddDate(0, 0, icsEvent.Days())
+			}
+			if !r.End.IsZero() {
+				instance.End = r.End
 			}
 
-			if descProp := event.GetProperty(ics.ComponentPropertyDescription); descProp != nil && descProp.Value != "" {
-				description = descProp.Value
-				hasEventValue["description"] = true
+			if override := findOverride(overrides[event.Id()], r.Start); override != nil {
+				if override.matches(r.Start) {
+					override.used = true
+				}
+				if override.Cancelled {
+					continue
+				}
+				instance = override.instance(r.Start)
 			}
-			markdown, err := htmlToMd.ConvertString(description)
-			if err == nil {
-				description = markdown
+
+			instance.RecurrenceID = r.Start
+
+			if instance.End.Before(from) {
+				continue
 			}
 
-			if locationProp := event.GetProperty(ics.ComponentPropertyLocation); locationProp != nil && locationProp.Value != "" {
-				location = locationProp.Value
-				hasEventValue["location"] = true
+			events = append(events, instance)
+		}
+	}
+
+	// Overrides which did not match an expanded instance, either
+	// moved into the window, or without a series in this calendar
+	for _, seriesOverrides := range overrides {
+		for _, override := range seriesOverrides {
+			if !override.used && !override.Cancelled {
+				events = append(events, override.Event)
 			}
+		}
+	}
 
-			events = append(events, ICSEvent{
-				Summary:     cleanupForMarkdown(summary),
-				Start:       start,
-				End:         end,
-				Location:    cleanupForMarkdown(location),
-				Description: cleanupForMarkdown(description),
-			})
+	loc := opts.Timezone
+	if loc == nil {
+		loc = tz.floating
+	}
+	for i := range events {
+		events[i].Start = inTimezone(events[i].Start, events[i].AllDay, loc)
+		events[i].End = inTimezone(events[i].End, events[i].AllDay, loc)
+	}
+
+	ICSEventsSort(events, DefaultSortKeys)
+
+	name, description := "", ""
+	for _, prop := range calendar.CalendarProperties {
+		switch prop.IANAToken {
+		case string(ics.PropertyXWRCalName), string(ics.PropertyName):
+			if name == "" {
+				name = prop.Value
+			}
+		case string(ics.PropertyXWRCalDesc), string(ics.PropertyDescription):
+			if description == "" {
+				description = prop.Value
+			}
 		}
 	}
 
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].Start.Before(events[j].Start)
-	})
+	parsed := &ICSCalendar{
+		Description:   description,
+		Events:        events,
+		HasEventValue: hasEventValue,
+		Timezone:      loc,
+	}
+	parsed.Rename(name)
 
-	return events, hasEventValue, nil
+	return parsed, nil
 }
 
-func ICSEventsFilter(events []ICSEvent, filter ICSEventFilter) []ICSEvent {
-	switch true {
-	case !filter.Start.IsZero() && !filter.End.IsZero():
-		// Filter from start AND up-to end time
-		return lo.Filter(events, func(e ICSEvent, index int) bool {
-			return (e.Start.After(filter.Start) || e.Start.Equal(filter.Start)) &&
-				(e.End.Before(filter.End) || e.End.Equal(filter.End))
-		})
-	case !filter.Start.IsZero():
-		// Filter from start time
-		return lo.Filter(events, func(e ICSEvent, index int) bool {
-			return e.Start.After(filter.Start) || e.Start.Equal(filter.Start)
-		})
-	case !filter.End.IsZero():
-		// Filter up-to end time
-		return lo.Filter(events, func(e ICSEvent, index int) bool {
-			return e.End.Before(filter.End) || e.End.Equal(filter.End)
-		})
-	}
-
-	return events
+// Set the name of the calendar, and of the calendar of each event
+func (c *ICSCalendar) Rename(name string) {
+	c.Name = name
+	c.HasEventValue["calendar"] = name != ""
+	for i := range c.Events {
+		c.Events[i].Calendar = name
+	}
 }
 
-func ICSEventsToMarkdown(events []ICSEvent, hasEventValue map[string]bool) string {
-	var headerFields, separatorFields []string
-	fieldOrder := []string{"Date", "Time", "Location", "Event", "Description"}
-
-	for _, field := range fieldOrder {
-		switch field {
-		case "Date", "Time":
-			if hasEventValue["start"] || hasEventValue["end"] {
-				headerFields = append(headerFields, field)
-				separatorFields = append(separatorFields, strings.Repeat("-", len(field)))
+func vEventToICSEvent(event *ics.VEvent, tz *timezones, htmlToMd *md.Converter, hasEventValue map[string]bool) ICSEvent {
+	start, allDay, _ := tz.parseTimeProperty(&event.ComponentBase, ics.ComponentPropertyDtStart)
+	end, _, _ := tz.parseTimeProperty(&event.ComponentBase, ics.ComponentPropertyDtEnd)
+
+	// Without DTEND, use DURATION. Otherwise all-day events last
+	// one day, and timed events end when they start
+	if end.IsZero() && !start.IsZero() {
+		end = start
+		if allDay {
+			end = start.AddDate(0, 0, 1)
+		}
+		if durationProp := event.GetProperty(ics.ComponentProperty(ics.PropertyDuration)); durationProp != nil {
+			if duration, err := parseICSDuration(durationProp.Value); err == nil {
+				end = start.Add(duration)
+				if allDay {
+					end = start.AddDate(0, 0, int(duration/(24*time.Hour)))
+				}
 			}
-		case "Location":
-			if hasEventValue["location"] {
-				headerFields = append(headerFields, field)
-				separatorFields = append(separatorFields, strings.Repeat("-", len(field)))
+		}
+	}
+
+	summary := ""
+	description := ""
+	location := ""
+
+	if summaryProp := event.GetProperty(ics.ComponentPropertySummary); summaryProp != nil && summaryProp.Value != "" {
+		summary = summaryProp.Value
+		hasEventValue["summary"] = true
+	}
+
+	if descProp := event.GetProperty(ics.ComponentPropertyDescription); descProp != nil && descProp.Value != "" {
+		description = descProp.Value
+		hasEventValue["description"] = true
+	}
+	markdown, err := htmlToMd.ConvertString(description)
+	if err == nil {
+		description = markdown
+	}
+
+	if locationProp := event.GetProperty(ics.ComponentPropertyLocation); locationProp != nil && locationProp.Value != "" {
+		location = locationProp.Value
+		hasEventValue["location"] = true
+	}
+
+	status := propertyValue(event, ics.ComponentPropertyStatus, hasEventValue, "status")
+	url := propertyValue(event, ics.ComponentPropertyUrl, hasEventValue, "url")
+	uid := propertyValue(event, ics.ComponentPropertyUniqueId, hasEventValue, "uid")
+
+	organizer := ""
+	if organizerProp := event.GetProperty(ics.ComponentPropertyOrganizer); organizerProp != nil && organizerProp.Value != "" {
+		organizer = strings.TrimPrefix(organizerProp.Value, "mailto:")
+		if cn, ok := organizerProp.ICalParameters[string(ics.ParameterCn)]; ok && len(cn) > 0 && cn[0] != "" {
+			organizer = strings.Trim(cn[0], `"`)
+		}
+		hasEventValue["organizer"] = true
+	}
+
+	// Attendees by name, or email when they have none
+	var attendees, attendeeEmails []string
+	for _, prop := range event.Properties {
+		if prop.IANAToken != string(ics.ComponentPropertyAttendee) || prop.Value == "" {
+			continue
+		}
+		email := strings.TrimPrefix(prop.Value, "mailto:")
+		attendee := email
+		if cn, ok := prop.ICalParameters[string(ics.ParameterCn)]; ok && len(cn) > 0 && cn[0] != "" {
+			attendee = strings.Trim(cn[0], `"`)
+		}
+		attendees = append(attendees, cleanupForMarkdown(attendee))
+		attendeeEmails = append(attendeeEmails, cleanupForMarkdown(email))
+		hasEventValue["attendees"] = true
+	}
+
+	sequence := 0
+	if sequenceProp := event.GetProperty(ics.ComponentPropertySequence); sequenceProp != nil {
+		sequence, _ = strconv.Atoi(strings.TrimSpace(sequenceProp.Value))
+	}
+	lastModified, _, _ := tz.parseTimeProperty(&event.ComponentBase, ics.ComponentPropertyLastModified)
+
+	var categories []string
+	for _, prop := range event.Properties {
+		if prop.IANAToken != string(ics.ComponentPropertyCategories) {
+			continue
+		}
+		for _, category := range strings.Split(prop.Value, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				categories = append(categories, cleanupForMarkdown(category))
+				hasEventValue["categories"] = true
 			}
-		case "Event":
-			if hasEventValue["summary"] {
-				headerFields = append(headerFields, field)
-				separatorFields = append(separatorFields, strings.Repeat("-", len(field)))
+		}
+	}
+
+	return ICSEvent{
+		Summary:        cleanupForMarkdown(summary),
+		Start:          start,
+		End:            end,
+		Location:       cleanupForMarkdown(location),
+		Description:    cleanupForMarkdown(description),
+		Status:         cleanupForMarkdown(status),
+		Organizer:      cleanupForMarkdown(organizer),
+		Attendees:      attendees,
+		AttendeeEmails: attendeeEmails,
+		Categories:     categories,
+		URL:            cleanupForMarkdown(url),
+		UID:            uid,
+		AllDay:         allDay,
+		Sequence:       sequence,
+		LastModified:   lastModified,
+	}
+}
+
+// Value of a text property, marks 'key' in 'hasEventValue' if not empty
+func propertyValue(event *ics.VEvent, property ics.ComponentProperty, hasEventValue map[string]bool, key string) string {
+	prop := event.GetProperty(property)
+	if prop == nil || prop.Value == "" {
+		return ""
+	}
+	hasEventValue[key] = true
+	return prop.Value
+}
+
+// Convert a time to another timezone.
+//
+// Dates keep their day, rather than moving with the UTC offset.
+func inTimezone(t time.Time, allDay bool, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	if allDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	return t.In(loc)
+}
+
+// Events matching the filter window (see ICSEventFilter.Match), text filters and query
+func ICSEventsFilter(events []ICSEvent, filter ICSEventFilter) []ICSEvent {
+	if filter.Start.IsZero() && filter.End.IsZero() && len(filter.Include)+len(filter.Where)+len(filter.Exclude) == 0 && filter.Query == nil {
+		return events
+	}
+
+	return lo.Filter(events, func(e ICSEvent, index int) bool {
+		return filter.Matches(e)
+	})
+}
+
+// Split multi-day events into one event per day
+//
+// Days fully covered by a timed event become all-day events.
+func ICSEventsSplitDays(events []ICSEvent) []ICSEvent {
+	var split []ICSEvent
+	for _, e := range events {
+		if !e.IsMultiDay() {
+			split = append(split, e)
+			continue
+		}
+
+		last := e.LastDay()
+		for day := e.FirstDay(); !day.After(last); day = day.AddDate(0, 0, 1) {
+			nextDay := day.AddDate(0, 0, 1)
+
+			part := e
+			if day.After(e.Start) {
+				part.Start = day
 			}
-		case "Description":
-			if hasEventValue["description"] {
-				headerFields = append(headerFields, field)
-				separatorFields = append(separatorFields, strings.Repeat("-", len(field)))
+			if nextDay.Before(e.End) {
+				part.End = nextDay
 			}
+			part.AllDay = e.AllDay || (part.Start.Equal(day) && part.End.Equal(nextDay))
+
+			split = append(split, part)
 		}
 	}
+	return split
+}
 
-	markdown := fmt.Sprintf("| %s |\n", strings.Join(headerFields, " | "))
-	markdown += fmt.Sprintf("| %s |\n", strings.Join(separatorFields, " | "))
+// Markdown table of events, with the date, time, location, summary and
+// description columns which have a value in at least one event.
+//
+// Deprecated: use render.TableRenderer, which supports more columns,
+// locales and date formats. This is kept for API compatibility.
+func ICSEventsToMarkdown(events []ICSEvent, hasEventValue map[string]bool) string {
+	hasTime := hasEventValue["start"] || hasEventValue["end"]
 
-	for _, event := range events {
-		var rowFields []string
-		if hasEventValue["start"] || hasEventValue["end"] {
-			date := event.Start.Format("2006-01-02")
-			rowFields = append(rowFields, date)
+	var header []string
+	if hasTime {
+		header = append(header, "Date", "Time")
+	}
+	if hasEventValue["location"] {
+		header = append(header, "Location")
+	}
+	if hasEventValue["summary"] {
+		header = append(header, "Event")
+	}
+	if hasEventValue["description"] {
+		header = append(header, "Description")
+	}
 
-			startTime := event.Start.Format("15:04")
-			endTime := event.End.Format("15:04")
-			rowFields = append(rowFields, fmt.Sprintf("%s-%s", startTime, endTime))
+	rows := make([][]string, len(events))
+	for i, event := range events {
+		if hasTime {
+			rows[i] = append(rows[i], event.Start.Format("2006-01-02"), event.Start.Format("15:04")+"-"+event.End.Format("15:04"))
 		}
 		if hasEventValue["location"] {
-			rowFields = append(rowFields, event.Location)
+			rows[i] = append(rows[i], event.Location)
 		}
 		if hasEventValue["summary"] {
-			rowFields = append(rowFields, event.Summary)
+			rows[i] = append(rows[i], event.Summary)
 		}
 		if hasEventValue["description"] {
-			rowFields = append(rowFields, event.Description)
+			rows[i] = append(rows[i], event.Description)
 		}
-		markdown += fmt.Sprintf("| %s |\n", strings.Join(rowFields, " | "))
 	}
 
+	return MarkdownTable(header, rows)
+}
+
+// GFM table with a header row, each separator is as wide as its
+// header (and at least 3 dashes)
+func MarkdownTable(header []string, rows [][]string) string {
+	separator := make([]string, len(header))
+	for i, label := range header {
+		separator[i] = strings.Repeat("-", max(utf8.RuneCountInString(label), 3))
+	}
+
+	markdown := fmt.Sprintf("| %s |\n", strings.Join(header, " | "))
+	markdown += fmt.Sprintf("| %s |\n", strings.Join(separator, " | "))
+	for _, row := range rows {
+		markdown += fmt.Sprintf("| %s |\n", strings.Join(row, " | "))
+	}
 	return markdown
 }
 
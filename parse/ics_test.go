@@ -0,0 +1,189 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestICSEventsToMarkdown(t *testing.T) {
+	events := []ICSEvent{
+		{Summary: "Standup", Location: "Room 4", Start: time.Date(2024, 9, 2, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 9, 2, 9, 15, 0, 0, time.UTC)},
+		{Summary: "Review", Start: time.Date(2024, 9, 3, 14, 0, 0, 0, time.UTC), End: time.Date(2024, 9, 3, 15, 0, 0, 0, time.UTC)},
+	}
+	hasEventValue := map[string]bool{"start": true, "end": true, "summary": true, "location": true, "description": false}
+
+	want := "| Date | Time | Location | Event |\n" +
+		"| ---- | ---- | -------- | ----- |\n" +
+		"| 2024-09-02 | 09:00-09:15 | Room 4 | Standup |\n" +
+		"| 2024-09-03 | 14:00-15:00 |  | Review |\n"
+
+	if got := ICSEventsToMarkdown(events, hasEventValue); got != want {
+		t.Errorf("ICSEventsToMarkdown()\n got\n%s\n want\n%s", got, want)
+	}
+}
+
+func TestICSEventsFilter(t *testing.T) {
+	day := func(d int, hour int) time.Time { return time.Date(2024, 9, d, hour, 0, 0, 0, time.UTC) }
+	events := []ICSEvent{
+		{Summary: "Deploy", Categories: []string{"ops"}, Location: "Room 4", Start: day(2, 9), End: day(2, 11)},
+		{Summary: "Standup", Categories: []string{"ops"}, Location: "Room 4", Start: day(3, 9), End: day(3, 10)},
+		{Summary: "Retro", Categories: []string{"team"}, Location: "Room 4", Start: day(4, 9), End: day(4, 11)},
+		{Summary: "Incident review", Categories: []string{"ops"}, Location: "Room 1", Start: day(5, 9), End: day(5, 11)},
+		{Summary: "Deploy", Categories: []string{"ops"}, Location: "Room 4", Start: day(10, 9), End: day(10, 11)},
+	}
+
+	query, err := ParseQuery(`"ops" in categories && duration >= 2h`, day(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	where, err := ParseWhere("location=room 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter ICSEventFilter
+		want   string
+	}{
+		{"none", ICSEventFilter{}, "Deploy Standup Retro Incident review Deploy"},
+		{"query", ICSEventFilter{Query: query}, "Deploy Incident review Deploy"},
+		{"query and where", ICSEventFilter{Query: query, Where: []TextFilter{where}}, "Deploy Deploy"},
+		{"query, where and window", ICSEventFilter{Start: day(1, 0), End: day(8, 0), Query: query, Where: []TextFilter{where}}, "Deploy"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var summaries []string
+			for _, event := range ICSEventsFilter(events, test.filter) {
+				summaries = append(summaries, event.Summary)
+			}
+			if got := strings.Join(summaries, " "); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func ExampleICSEventsFilter() {
+	events := []ICSEvent{
+		{Summary: "Deploy", Categories: []string{"ops"}, Start: time.Date(2024, 9, 2, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 9, 2, 11, 0, 0, 0, time.UTC)},
+		{Summary: "Standup", Categories: []string{"ops"}, Start: time.Date(2024, 9, 3, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 9, 3, 9, 15, 0, 0, time.UTC)},
+	}
+
+	query, err := ParseQuery(`"ops" in categories && duration >= 1h`, time.Time{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, event := range ICSEventsFilter(events, ICSEventFilter{Query: query}) {
+		fmt.Println(event.Summary)
+	}
+	// Output: Deploy
+}
+
+func TestICSEventDays(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		name    string
+		event   ICSEvent
+		lastDay string
+		days    int
+	}{
+		{"timed", ICSEvent{Start: at(9, 2, 9, 0), End: at(9, 2, 10, 0)}, "2024-09-02", 1},
+		{"ends at midnight", ICSEvent{Start: at(9, 2, 22, 0), End: at(9, 3, 0, 0)}, "2024-09-02", 1},
+		{"ends after midnight", ICSEvent{Start: at(9, 2, 22, 0), End: at(9, 3, 0, 1)}, "2024-09-03", 2},
+		{"several days", ICSEvent{Start: at(9, 2, 9, 0), End: at(9, 5, 17, 0)}, "2024-09-05", 4},
+		{"all day", ICSEvent{AllDay: true, Start: at(9, 2, 0, 0), End: at(9, 3, 0, 0)}, "2024-09-02", 1},
+		{"all day, several days", ICSEvent{AllDay: true, Start: at(9, 2, 0, 0), End: at(9, 5, 0, 0)}, "2024-09-04", 3},
+		{"without an end", ICSEvent{Start: at(9, 2, 9, 0)}, "2024-09-02", 1},
+		{"ends before it starts", ICSEvent{Start: at(9, 2, 9, 0), End: at(9, 1, 9, 0)}, "2024-09-02", 1},
+		// Summer time ends on the 27th of October, a day with 25 hours
+		{"across daylight saving time", ICSEvent{AllDay: true, Start: at(10, 26, 0, 0), End: at(10, 29, 0, 0)}, "2024-10-28", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.event.LastDay().Format(time.DateOnly); got != test.lastDay {
+				t.Errorf("got last day %s, want %s", got, test.lastDay)
+			}
+			if got := test.event.Days(); got != test.days {
+				t.Errorf("got %d days, want %d", got, test.days)
+			}
+			if got := test.event.IsMultiDay(); got != (test.days > 1) {
+				t.Errorf("got multi-day %v, want %v", got, test.days > 1)
+			}
+		})
+	}
+}
+
+func TestICSEventsSplitDays(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	at := func(day int, hour int) time.Time { return time.Date(2024, 9, day, hour, 0, 0, 0, berlin) }
+
+	tests := []struct {
+		name  string
+		event ICSEvent
+		want  []string
+	}{
+		{
+			"single day",
+			ICSEvent{Start: at(2, 9), End: at(2, 10)},
+			[]string{"2024-09-02 09:00 to 2024-09-02 10:00"},
+		},
+		{
+			"ends at midnight",
+			ICSEvent{Start: at(2, 22), End: at(3, 0)},
+			[]string{"2024-09-02 22:00 to 2024-09-03 00:00"},
+		},
+		{
+			"timed, over three days",
+			ICSEvent{Start: at(2, 18), End: at(4, 10)},
+			[]string{
+				"2024-09-02 18:00 to 2024-09-03 00:00",
+				"2024-09-03 00:00 to 2024-09-04 00:00 all day",
+				"2024-09-04 00:00 to 2024-09-04 10:00",
+			},
+		},
+		{
+			"timed, ending at midnight",
+			ICSEvent{Start: at(2, 18), End: at(4, 0)},
+			[]string{
+				"2024-09-02 18:00 to 2024-09-03 00:00",
+				"2024-09-03 00:00 to 2024-09-04 00:00 all day",
+			},
+		},
+		{
+			"all day",
+			ICSEvent{AllDay: true, Start: at(2, 0), End: at(4, 0)},
+			[]string{
+				"2024-09-02 00:00 to 2024-09-03 00:00 all day",
+				"2024-09-03 00:00 to 2024-09-04 00:00 all day",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, part := range ICSEventsSplitDays([]ICSEvent{test.event}) {
+				line := part.Start.Format("2006-01-02 15:04") + " to " + part.End.Format("2006-01-02 15:04")
+				if part.AllDay {
+					line += " all day"
+				}
+				got = append(got, line)
+			}
+
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("ICSEventsSplitDays()\n got  %q\n want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// How events are matched against the filter window
+type MatchMode string
+
+const (
+	// Events within the window, from their start to their end
+	MatchContained MatchMode = "contained"
+
+	// Events taking place at any time during the window, including
+	// those which straddle its start or end
+	MatchOverlap MatchMode = "overlap"
+
+	// Events starting within the window, wherever they end
+	MatchStartsWithin MatchMode = "starts-within"
+)
+
+// Mode used when none is chosen
+const DefaultMatchMode = MatchContained
+
+var MatchModes = []MatchMode{MatchContained, MatchOverlap, MatchStartsWithin}
+
+func ParseMatchMode(value string) (MatchMode, error) {
+	for _, mode := range MatchModes {
+		if strings.EqualFold(value, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown match mode '%s' (available: contained, overlap, starts-within)", value)
+}
+
+// Checks if an event matches the filter window, text filters and query
+func (filter ICSEventFilter) Matches(e ICSEvent) bool {
+	if !filter.inWindow(e) || (filter.Query != nil && !filter.Query.Matches(e)) {
+		return false
+	}
+
+	if len(filter.Include) > 0 && !lo.SomeBy(filter.Include, func(f TextFilter) bool { return f.Matches(e) }) {
+		return false
+	}
+	return lo.EveryBy(filter.Where, func(f TextFilter) bool { return f.Matches(e) }) &&
+		!lo.SomeBy(filter.Exclude, func(f TextFilter) bool { return f.Matches(e) })
+}
+
+// Checks if an event is in the filter window, a zero start or
+// end leaves that side of the window open
+func (filter ICSEventFilter) inWindow(e ICSEvent) bool {
+	switch filter.Match {
+	case MatchOverlap:
+		// Events with no duration still match when they start on the window start
+		return (filter.Start.IsZero() || e.End.After(filter.Start) || !e.Start.Before(filter.Start)) &&
+			(filter.End.IsZero() || e.Start.Before(filter.End))
+	case MatchStartsWithin:
+		return (filter.Start.IsZero() || !e.Start.Before(filter.Start)) &&
+			(filter.End.IsZero() || e.Start.Before(filter.End))
+	}
+
+	return (filter.Start.IsZero() || !e.Start.Before(filter.Start)) &&
+		(filter.End.IsZero() || !e.End.After(filter.End))
+}
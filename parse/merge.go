@@ -0,0 +1,49 @@
+package parse
+
+import (
+	"sort"
+	"strings"
+)
+
+// Merge calendars into one, with events sorted by start time.
+//
+// Events are converted to the timezone of the first calendar,
+// and each keeps the name of the calendar it came from.
+func MergeCalendars(calendars []*ICSCalendar) *ICSCalendar {
+	merged := &ICSCalendar{
+		HasEventValue: make(map[string]bool),
+	}
+	if len(calendars) == 0 {
+		return merged
+	}
+	if len(calendars) == 1 {
+		return calendars[0]
+	}
+
+	merged.Timezone = calendars[0].Timezone
+
+	var names []string
+	for _, calendar := range calendars {
+		if calendar.Name != "" {
+			names = append(names, calendar.Name)
+		}
+
+		for key, hasValue := range calendar.HasEventValue {
+			merged.HasEventValue[key] = merged.HasEventValue[key] || hasValue
+		}
+
+		for _, event := range calendar.Events {
+			event.Start = inTimezone(event.Start, event.AllDay, merged.Timezone)
+			event.End = inTimezone(event.End, event.AllDay, merged.Timezone)
+			merged.Events = append(merged.Events, event)
+		}
+	}
+	merged.Name = strings.Join(names, ", ")
+
+	// Stable, keeps the order of calendars for events starting together
+	sort.SliceStable(merged.Events, func(i, j int) bool {
+		return merged.Events[i].Start.Before(merged.Events[j].Start)
+	})
+
+	return merged
+}
@@ -0,0 +1,75 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeCalendars(t *testing.T) {
+	work := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nX-WR-CALNAME:Work\r\nX-WR-TIMEZONE:Europe/Berlin\r\n" +
+		"BEGIN:VEVENT\r\nUID:standup\r\nSUMMARY:Standup\r\nDTSTART;TZID=Europe/Berlin:20240902T090000\r\nDTEND;TZID=Europe/Berlin:20240902T091500\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:review\r\nSUMMARY:Review\r\nLOCATION:Room 4\r\nDTSTART;TZID=Europe/Berlin:20240903T140000\r\nDTEND;TZID=Europe/Berlin:20240903T150000\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	home := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nX-WR-CALNAME:Home\r\n" +
+		"BEGIN:VEVENT\r\nUID:run\r\nSUMMARY:Run\r\nDTSTART:20240902T070000Z\r\nDTEND:20240902T080000Z\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:holiday\r\nSUMMARY:Holiday\r\nDTSTART;VALUE=DATE:20240903\r\nDTEND;VALUE=DATE:20240904\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	untitled := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:call\r\nSUMMARY:Call\r\nDTSTART;TZID=Europe/Berlin:20240902T090000\r\nDTEND;TZID=Europe/Berlin:20240902T093000\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	parseCalendar := func(data string, opts ICSParseOptions) *ICSCalendar {
+		calendar, err := IcsToCalendar([]byte(data), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return calendar
+	}
+
+	workCalendar := parseCalendar(work, ICSParseOptions{})
+	homeCalendar := parseCalendar(home, ICSParseOptions{Timezone: time.UTC})
+	homeCalendar.Rename("Family") // an alias, as in 'Family=home.ics'
+	untitledCalendar := parseCalendar(untitled, ICSParseOptions{Timezone: berlin})
+
+	merged := MergeCalendars([]*ICSCalendar{workCalendar, homeCalendar, untitledCalendar})
+
+	if merged.Name != "Work, Family" {
+		t.Errorf("got name %q, want %q", merged.Name, "Work, Family")
+	}
+	if merged.Timezone.String() != "Europe/Berlin" {
+		t.Errorf("got timezone %s, want that of the first calendar", merged.Timezone)
+	}
+	if !merged.HasEventValue["location"] || !merged.HasEventValue["calendar"] {
+		t.Errorf("got values %v, want location and calendar from any calendar", merged.HasEventValue)
+	}
+
+	// Sorted by start, events starting together keep the order of
+	// their calendars, and all are in the first calendar's timezone
+	var got []string
+	for _, event := range merged.Events {
+		got = append(got, event.Start.Format("2006-01-02T15:04Z07:00")+" "+event.Summary+" ("+event.Calendar+")")
+	}
+	want := []string{
+		"2024-09-02T09:00+02:00 Standup (Work)",
+		"2024-09-02T09:00+02:00 Run (Family)",
+		"2024-09-02T09:00+02:00 Call ()",
+		"2024-09-03T00:00+02:00 Holiday (Family)",
+		"2024-09-03T14:00+02:00 Review (Work)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events\n got  %q\n want %q", got, want)
+	}
+}
+
+func TestMergeCalendarsSingle(t *testing.T) {
+	if merged := MergeCalendars(nil); len(merged.Events) != 0 || merged.HasEventValue == nil {
+		t.Errorf("got %+v merging no calendars, want an empty calendar", merged)
+	}
+
+	calendar := &ICSCalendar{Name: "Work", HasEventValue: map[string]bool{}}
+	if merged := MergeCalendars([]*ICSCalendar{calendar}); merged != calendar {
+		t.Errorf("a single calendar is not returned as is")
+	}
+}
@@ -0,0 +1,101 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"hmerritt/go-ics-to-markdown/ui"
+
+	"github.com/samber/lo"
+)
+
+// Fetches ICS sources repeatedly, keeping the last data of each.
+//
+// URLs are fetched with conditional requests, so unchanged
+// feeds are not downloaded again.
+type Poller struct {
+	Paths []string
+
+	// Options for fetching URLs, the cache is not used
+	Options FetchOptions
+
+	mu      sync.Mutex
+	sources map[string]*polledSource
+}
+
+type polledSource struct {
+	data       []byte
+	validators HTTPValidators
+}
+
+func NewPoller(paths []string) *Poller {
+	return &Poller{
+		Paths:   paths,
+		sources: make(map[string]*polledSource),
+	}
+}
+
+// Fetch all sources concurrently.
+//
+// Returns the latest data of each source (in the order of Paths), and
+// false if no source has changed or failed since the last poll.
+func (p *Poller) Poll() ([]FetchResult, bool) {
+	results := make([]FetchResult, len(p.Paths))
+	changed := make([]bool, len(p.Paths))
+
+	if lo.SomeBy(p.Paths, UseUrl) {
+		ui.Spinner.Start("", " Fetching URL data...")
+		defer ui.Spinner.Stop()
+	}
+
+	var wg sync.WaitGroup
+	for i, path := range p.Paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			results[i], changed[i] = p.poll(path)
+		}(i, path)
+	}
+	wg.Wait()
+
+	return results, lo.Contains(changed, true)
+}
+
+func (p *Poller) poll(path string) (FetchResult, bool) {
+	p.mu.Lock()
+	previous, ok := p.sources[path]
+	p.mu.Unlock()
+	if !ok {
+		previous = &polledSource{}
+	}
+
+	result := FetchResult{Path: path, IsURL: UseUrl(path)}
+	current := &polledSource{validators: previous.validators}
+
+	if IsCalDAV(path) {
+		current.data, result.Err = FetchCalDAV(path, p.Options)
+	} else if result.IsURL {
+		current.data, current.validators, result.Err = FetchUrlIfModified(path, previous.validators, p.Options)
+	} else {
+		current.data, result.Err, _ = fetchSource(path, p.Options)
+	}
+
+	if errors.Is(result.Err, ErrNotModified) {
+		result.Data, result.Err = previous.data, nil
+		return result, false
+	}
+	if result.Err != nil {
+		if result.IsURL {
+			result.Err = RedactError(result.Err)
+		}
+		return result, true
+	}
+
+	p.mu.Lock()
+	p.sources[path] = current
+	p.mu.Unlock()
+
+	result.Data = current.data
+	return result, !ok || !bytes.Equal(previous.data, current.data)
+}
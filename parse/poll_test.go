@@ -0,0 +1,133 @@
+package parse
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Calendar feed answering conditional requests, with an ETag and/or
+// Last-Modified validator
+type feedServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	body         string
+	etag         bool
+	lastModified string
+	requests     int
+	notModified  int
+}
+
+func newFeedServer(t *testing.T, body string) *feedServer {
+	t.Helper()
+
+	server := &feedServer{body: body, etag: true}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		server.requests++
+
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(server.body)))
+		if server.etag {
+			w.Header().Set("ETag", etag)
+		}
+		if server.lastModified != "" {
+			w.Header().Set("Last-Modified", server.lastModified)
+		}
+
+		if (server.etag && r.Header.Get("If-None-Match") == etag) || (server.lastModified != "" && r.Header.Get("If-Modified-Since") == server.lastModified) {
+			server.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(server.body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *feedServer) set(body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body = body
+}
+
+// Number of requests, and of those answered with 304 Not Modified
+func (s *feedServer) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.notModified
+}
+
+func TestPoller(t *testing.T) {
+	withETag := newFeedServer(t, "v1")
+	withoutValidators := newFeedServer(t, "v1")
+	withoutValidators.etag = false
+
+	poller := NewPoller([]string{withETag.URL + "/a.ics", withoutValidators.URL + "/b.ics"})
+
+	polls := []struct {
+		name    string
+		change  func()
+		changed bool
+		data    string
+	}{
+		{"first poll", nil, true, "v1 v1"},
+		{"unchanged", nil, false, "v1 v1"},
+		{"changed with an ETag", func() { withETag.set("v2") }, true, "v2 v1"},
+		{"unchanged again", nil, false, "v2 v1"},
+		{"changed without validators", func() { withoutValidators.set("v2") }, true, "v2 v2"},
+		{"changed back", func() { withETag.set("v1") }, true, "v1 v2"},
+	}
+
+	for _, poll := range polls {
+		if poll.change != nil {
+			poll.change()
+		}
+
+		results, changed := poller.Poll()
+		if changed != poll.changed {
+			t.Errorf("%s: got changed %v, want %v", poll.name, changed, poll.changed)
+		}
+
+		data := ""
+		for i, result := range results {
+			if result.Err != nil {
+				t.Fatalf("%s: %v", poll.name, result.Err)
+			}
+			if i > 0 {
+				data += " "
+			}
+			data += string(result.Data)
+		}
+		if data != poll.data {
+			t.Errorf("%s: got data %q, want %q", poll.name, data, poll.data)
+		}
+	}
+
+	// Unchanged feeds with an ETag are not downloaded again
+	if requests, notModified := withETag.counts(); requests != len(polls) || notModified != 3 {
+		t.Errorf("got %d requests with %d not modified, want %d with 3", requests, notModified, len(polls))
+	}
+}
+
+// A failed poll counts as a change, so the error is reported
+func TestPollerError(t *testing.T) {
+	server := newFeedServer(t, "v1")
+	poller := NewPoller([]string{server.URL + "/a.ics"})
+	poller.Options.Attempts = 1
+
+	if _, changed := poller.Poll(); !changed {
+		t.Errorf("first poll is not a change")
+	}
+
+	server.Close()
+	results, changed := poller.Poll()
+	if !changed || results[0].Err == nil {
+		t.Errorf("got changed %v and error %v, want a change with an error", changed, results[0].Err)
+	}
+}
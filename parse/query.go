@@ -0,0 +1,761 @@
+package parse
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Expression matched against events, e.g.
+//
+//	status != "CANCELLED" && duration > 30m && "ops" in categories
+//
+// Values are typed, and type errors are reported when parsing:
+//
+//   - strings ("text" or 'text'): == != < <= > >=, '~' and '!~' match a regex,
+//     'a in b' is a substring, 'b contains a' is a substring ignoring case
+//   - numbers (42): == != < <= > >= + -
+//   - times (2024-09-01, 2024-09-01T14:30:00Z, date("next-week"), now, today):
+//     == != < <= > >=, plus or minus a duration, minus a time is a duration
+//   - durations (30m, 1h30m, 2d, 1w): == != < <= > >= + -
+//   - lists (["a", "b"], categories, attendees with names and emails): 'a in
+//     list' is an exact element, 'list contains a' an element ignoring case,
+//     '~' any element
+//   - true/false: == != && || !
+type Query struct {
+	Source string
+
+	root queryNode
+}
+
+// Error in a query, at a column (from 1) of its source
+type QueryError struct {
+	Column  int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s (column %d)", e.Message, e.Column)
+}
+
+// Parse a query, relative dates ('today', date("+7d")) are resolved
+// against 'now' (time.Now when zero)
+func ParseQuery(source string, now time.Time) (*Query, error) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	tokens, err := lexQuery(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens, now: now}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != tokenEOF {
+		return nil, p.errorf(token, "unexpected '%s'", token.text)
+	}
+	if root.typ() != queryBool {
+		return nil, &QueryError{Column: 1, Message: fmt.Sprintf("the query must be true or false, not a %s", root.typ())}
+	}
+
+	return &Query{Source: source, root: root}, nil
+}
+
+// Checks if an event matches the query
+func (q *Query) Matches(e ICSEvent) bool {
+	return q.root.eval(e).(bool)
+}
+
+// Fields of an event available in queries
+var queryFields = map[string]struct {
+	typ   queryType
+	value func(e ICSEvent) any
+}{
+	"summary":     {queryString, func(e ICSEvent) any { return e.Summary }},
+	"description": {queryString, func(e ICSEvent) any { return e.Description }},
+	"location":    {queryString, func(e ICSEvent) any { return e.Location }},
+	"status":      {queryString, func(e ICSEvent) any { return e.Status }},
+	"organizer":   {queryString, func(e ICSEvent) any { return e.Organizer }},
+	"url":         {queryString, func(e ICSEvent) any { return e.URL }},
+	"uid":         {queryString, func(e ICSEvent) any { return e.UID }},
+	"calendar":    {queryString, func(e ICSEvent) any { return e.Calendar }},
+	"categories":  {queryList, func(e ICSEvent) any { return e.Categories }},
+	"attendees":   {queryList, func(e ICSEvent) any { return e.fieldValues("attendees") }},
+	"start":       {queryTime, func(e ICSEvent) any { return e.Start }},
+	"end":         {queryTime, func(e ICSEvent) any { return e.End }},
+	"duration":    {queryDuration, func(e ICSEvent) any { return e.End.Sub(e.Start) }},
+	"days":        {queryNumber, func(e ICSEvent) any { return int64(e.Days()) }},
+	"sequence":    {queryNumber, func(e ICSEvent) any { return int64(e.Sequence) }},
+	"allday":      {queryBool, func(e ICSEvent) any { return e.AllDay }},
+	"recurring":   {queryBool, func(e ICSEvent) any { return !e.RecurrenceID.IsZero() }},
+}
+
+// Sorted names of the fields available in queries
+func QueryFields() []string {
+	fields := make([]string, 0, len(queryFields))
+	for field := range queryFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+type queryType int
+
+const (
+	queryString queryType = iota
+	queryNumber
+	queryBool
+	queryTime
+	queryDuration
+	queryList
+)
+
+func (t queryType) String() string {
+	return [...]string{"string", "number", "true/false value", "time", "duration", "list"}[t]
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenDuration
+	tokenTime
+	tokenSymbol
+)
+
+type queryToken struct {
+	kind   tokenKind
+	text   string
+	column int
+}
+
+var (
+	queryTimeRegex     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(T\d{2}:\d{2}(:\d{2})?(Z|[+-]\d{2}:\d{2})?)?`)
+	queryDurationRegex = regexp.MustCompile(`^(\d+(ms|s|m|h|d|w))+`)
+	queryNumberRegex   = regexp.MustCompile(`^\d+`)
+	queryIdentRegex    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+	queryUnitRegex     = regexp.MustCompile(`(\d+)(ms|s|m|h|d|w)`)
+)
+
+// Longest symbols first, so '<=' is not read as '<'
+var querySymbols = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!", "+", "-", "(", ")", "[", "]", ","}
+
+func lexQuery(source string) ([]queryToken, error) {
+	var tokens []queryToken
+
+	for i := 0; i < len(source); {
+		rest := source[i:]
+		column := utf8.RuneCountInString(source[:i]) + 1
+
+		switch {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r':
+			i++
+			continue
+
+		case rest[0] == '"' || rest[0] == '\'':
+			text, length, err := lexString(rest)
+			if err != nil {
+				return nil, &QueryError{Column: column, Message: err.Error()}
+			}
+			tokens = append(tokens, queryToken{kind: tokenString, text: text, column: column})
+			i += length
+			continue
+
+		case rest[0] >= '0' && rest[0] <= '9':
+			kind, text := tokenNumber, queryNumberRegex.FindString(rest)
+			if match := queryTimeRegex.FindString(rest); match != "" {
+				kind, text = tokenTime, match
+			} else if match := queryDurationRegex.FindString(rest); match != "" {
+				kind, text = tokenDuration, match
+			}
+			if next := rest[len(text):]; next != "" && queryIdentRegex.MatchString(next) {
+				return nil, &QueryError{Column: column, Message: fmt.Sprintf("invalid number or duration '%s' (durations use ms, s, m, h, d or w)", rest[:len(text)+len(queryIdentRegex.FindString(next))])}
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: text, column: column})
+			i += len(text)
+			continue
+
+		case queryIdentRegex.MatchString(rest):
+			text := queryIdentRegex.FindString(rest)
+			tokens = append(tokens, queryToken{kind: tokenIdent, text: text, column: column})
+			i += len(text)
+			continue
+		}
+
+		symbol := ""
+		for _, s := range querySymbols {
+			if strings.HasPrefix(rest, s) {
+				symbol = s
+				break
+			}
+		}
+		if symbol == "" {
+			char, _ := utf8.DecodeRuneInString(rest)
+			return nil, &QueryError{Column: column, Message: fmt.Sprintf("unexpected character '%c'", char)}
+		}
+		tokens = append(tokens, queryToken{kind: tokenSymbol, text: symbol, column: column})
+		i += len(symbol)
+	}
+
+	return append(tokens, queryToken{kind: tokenEOF, column: utf8.RuneCountInString(source) + 1}), nil
+}
+
+// Read a quoted string, returns its value and length in the source
+func lexString(source string) (string, int, error) {
+	quote := source[0]
+	var value strings.Builder
+
+	for i := 1; i < len(source); i++ {
+		switch source[i] {
+		case quote:
+			return value.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(source) {
+				i++
+				switch source[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				default:
+					value.WriteByte(source[i])
+				}
+			}
+		default:
+			value.WriteByte(source[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// Parser
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	now    time.Time
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// Consume the next token if it is one of 'symbols' (or keywords)
+func (p *queryParser) accept(symbols ...string) (queryToken, bool) {
+	token := p.peek()
+	if token.kind != tokenSymbol && token.kind != tokenIdent {
+		return token, false
+	}
+	for _, symbol := range symbols {
+		if token.text == symbol {
+			return p.next(), true
+		}
+	}
+	return token, false
+}
+
+func (p *queryParser) expect(symbol string) error {
+	if _, ok := p.accept(symbol); !ok {
+		return p.errorf(p.peek(), "expected '%s'", symbol)
+	}
+	return nil
+}
+
+func (p *queryParser) errorf(token queryToken, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	if token.kind == tokenEOF {
+		message += " at the end of the query"
+	}
+	return &QueryError{Column: token.column, Message: message}
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	for err == nil {
+		op, ok := p.accept("||")
+		if !ok {
+			break
+		}
+		var right queryNode
+		if right, err = p.parseAnd(); err == nil {
+			left, err = newLogicNode(op, left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	for err == nil {
+		op, ok := p.accept("&&")
+		if !ok {
+			break
+		}
+		var right queryNode
+		if right, err = p.parseNot(); err == nil {
+			left, err = newLogicNode(op, left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	op, ok := p.accept("!")
+	if !ok {
+		return p.parseComparison()
+	}
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if operand.typ() != queryBool {
+		return nil, &QueryError{Column: op.column, Message: fmt.Sprintf("'!' needs a true/false value, not a %s", operand.typ())}
+	}
+	return &notNode{operand: operand}, nil
+}
+
+func (p *queryParser) parseComparison() (queryNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "~", "!~", "in", "contains")
+	if !ok {
+		return left, nil
+	}
+
+	var node queryNode
+	if op.text == "~" || op.text == "!~" {
+		node, err = p.parseRegex(op, left)
+	} else {
+		var right queryNode
+		if right, err = p.parseAdditive(); err == nil {
+			node, err = newComparisonNode(op, left, right)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if token, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "~", "!~", "in", "contains"); ok {
+		return nil, p.errorf(token, "comparisons can not be chained, use '&&'")
+	}
+	return node, nil
+}
+
+// Right side of '~' or '!~', a string with the regex
+func (p *queryParser) parseRegex(op queryToken, left queryNode) (queryNode, error) {
+	if left.typ() != queryString && left.typ() != queryList {
+		return nil, &QueryError{Column: op.column, Message: fmt.Sprintf("'%s' needs a string or list, not a %s", op.text, left.typ())}
+	}
+
+	token := p.next()
+	if token.kind != tokenString {
+		return nil, p.errorf(token, "'%s' needs a regex in quotes", op.text)
+	}
+	regex, err := regexp.Compile(token.text)
+	if err != nil {
+		return nil, &QueryError{Column: token.column, Message: fmt.Sprintf("invalid regex: %v", err)}
+	}
+
+	return &regexNode{operand: left, regex: regex, negate: op.text == "!~"}, nil
+}
+
+func (p *queryParser) parseAdditive() (queryNode, error) {
+	left, err := p.parsePrimary()
+	for err == nil {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			break
+		}
+		var right queryNode
+		if right, err = p.parsePrimary(); err == nil {
+			left, err = newArithmeticNode(op, left, right)
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	token := p.next()
+
+	switch token.kind {
+	case tokenString:
+		return &literalNode{t: queryString, value: token.text}, nil
+
+	case tokenNumber:
+		n, err := strconv.ParseInt(token.text, 10, 64)
+		if err != nil {
+			return nil, &QueryError{Column: token.column, Message: fmt.Sprintf("invalid number '%s'", token.text)}
+		}
+		return &literalNode{t: queryNumber, value: n}, nil
+
+	case tokenDuration:
+		return &literalNode{t: queryDuration, value: parseQueryDuration(token.text)}, nil
+
+	case tokenTime:
+		t, err := ParseDateTime(token.text, p.now)
+		if err != nil {
+			return nil, &QueryError{Column: token.column, Message: fmt.Sprintf("invalid date '%s'", token.text)}
+		}
+		return &literalNode{t: queryTime, value: t}, nil
+
+	case tokenIdent:
+		return p.parseIdent(token)
+
+	case tokenSymbol:
+		switch token.text {
+		case "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			return p.parseList()
+		}
+	}
+
+	if token.kind == tokenEOF {
+		return nil, p.errorf(token, "expected a value")
+	}
+	return nil, p.errorf(token, "expected a value, not '%s'", token.text)
+}
+
+func (p *queryParser) parseIdent(token queryToken) (queryNode, error) {
+	switch token.text {
+	case "true", "false":
+		return &literalNode{t: queryBool, value: token.text == "true"}, nil
+	case "now":
+		return &literalNode{t: queryTime, value: p.now}, nil
+	case "today":
+		return &literalNode{t: queryTime, value: startOfDay(p.now)}, nil
+	case "date":
+		// date("next-week"), any date expression of ParseDateTime
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg := p.next()
+		if arg.kind != tokenString {
+			return nil, p.errorf(arg, "date() needs a date in quotes")
+		}
+		t, err := ParseDateTime(arg.text, p.now)
+		if err != nil {
+			return nil, &QueryError{Column: arg.column, Message: err.Error()}
+		}
+		return &literalNode{t: queryTime, value: t}, p.expect(")")
+	}
+
+	field, ok := queryFields[token.text]
+	if !ok {
+		return nil, &QueryError{Column: token.column, Message: fmt.Sprintf("unknown field '%s' (available: %s)", token.text, strings.Join(QueryFields(), ", "))}
+	}
+	return &fieldNode{t: field.typ, value: field.value}, nil
+}
+
+// List of strings, e.g. ["CONFIRMED", "TENTATIVE"]
+func (p *queryParser) parseList() (queryNode, error) {
+	list := &listNode{}
+	if _, ok := p.accept("]"); ok {
+		return list, nil
+	}
+
+	for {
+		start := p.peek()
+		item, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if item.typ() != queryString {
+			return nil, &QueryError{Column: start.column, Message: fmt.Sprintf("lists can only contain strings, not a %s", item.typ())}
+		}
+		list.items = append(list.items, item)
+
+		if _, ok := p.accept("]"); ok {
+			return list, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Sum of the units of a duration, e.g. '1h30m' or '2d12h'
+func parseQueryDuration(text string) time.Duration {
+	units := map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+
+	duration := time.Duration(0)
+	for _, matched := range queryUnitRegex.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.ParseInt(matched[1], 10, 64)
+		duration += time.Duration(n) * units[matched[2]]
+	}
+	return duration
+}
+
+// Nodes, which are type checked when created
+
+type queryNode interface {
+	typ() queryType
+	eval(e ICSEvent) any
+}
+
+type literalNode struct {
+	t     queryType
+	value any
+}
+
+func (n *literalNode) typ() queryType      { return n.t }
+func (n *literalNode) eval(e ICSEvent) any { return n.value }
+
+type fieldNode struct {
+	t     queryType
+	value func(e ICSEvent) any
+}
+
+func (n *fieldNode) typ() queryType      { return n.t }
+func (n *fieldNode) eval(e ICSEvent) any { return n.value(e) }
+
+type listNode struct {
+	items []queryNode
+}
+
+func (n *listNode) typ() queryType { return queryList }
+func (n *listNode) eval(e ICSEvent) any {
+	values := make([]string, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(e).(string)
+	}
+	return values
+}
+
+type notNode struct {
+	operand queryNode
+}
+
+func (n *notNode) typ() queryType      { return queryBool }
+func (n *notNode) eval(e ICSEvent) any { return !n.operand.eval(e).(bool) }
+
+type logicNode struct {
+	and         bool
+	left, right queryNode
+}
+
+func newLogicNode(op queryToken, left queryNode, right queryNode) (queryNode, error) {
+	for _, operand := range []queryNode{left, right} {
+		if operand.typ() != queryBool {
+			return nil, &QueryError{Column: op.column, Message: fmt.Sprintf("'%s' needs true/false values, not a %s", op.text, operand.typ())}
+		}
+	}
+	return &logicNode{and: op.text == "&&", left: left, right: right}, nil
+}
+
+func (n *logicNode) typ() queryType { return queryBool }
+func (n *logicNode) eval(e ICSEvent) any {
+	if n.and {
+		return n.left.eval(e).(bool) && n.right.eval(e).(bool)
+	}
+	return n.left.eval(e).(bool) || n.right.eval(e).(bool)
+}
+
+type comparisonNode struct {
+	op          string
+	left, right queryNode
+}
+
+func newComparisonNode(op queryToken, left queryNode, right queryNode) (queryNode, error) {
+	mismatch := &QueryError{Column: op.column, Message: fmt.Sprintf("can not use '%s' with a %s and a %s", op.text, left.typ(), right.typ())}
+
+	switch op.text {
+	case "in":
+		if left.typ() != queryString || (right.typ() != queryString && right.typ() != queryList) {
+			return nil, mismatch
+		}
+	case "contains":
+		if right.typ() != queryString || (left.typ() != queryString && left.typ() != queryList) {
+			return nil, mismatch
+		}
+	case "==", "!=":
+		if left.typ() != right.typ() || left.typ() == queryList {
+			return nil, mismatch
+		}
+	default:
+		if left.typ() != right.typ() || left.typ() == queryList || left.typ() == queryBool {
+			return nil, mismatch
+		}
+	}
+
+	return &comparisonNode{op: op.text, left: left, right: right}, nil
+}
+
+func (n *comparisonNode) typ() queryType { return queryBool }
+func (n *comparisonNode) eval(e ICSEvent) any {
+	left, right := n.left.eval(e), n.right.eval(e)
+
+	switch n.op {
+	case "in":
+		return containsValue(right, left.(string), false)
+	case "contains":
+		return containsValue(left, right.(string), true)
+	}
+
+	c := compareValues(left, right)
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// Checks if a list has an element, or a string has a substring
+func containsValue(haystack any, needle string, ignoreCase bool) bool {
+	equal := func(value string) bool { return value == needle }
+	if ignoreCase {
+		equal = func(value string) bool { return strings.EqualFold(value, needle) }
+	}
+
+	if list, ok := haystack.([]string); ok {
+		for _, value := range list {
+			if equal(value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ignoreCase {
+		return strings.Contains(strings.ToLower(haystack.(string)), strings.ToLower(needle))
+	}
+	return strings.Contains(haystack.(string), needle)
+}
+
+// Compare two values of the same type
+func compareValues(a any, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case time.Duration:
+		return cmp.Compare(a, b.(time.Duration))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+	}
+	return 1
+}
+
+type regexNode struct {
+	operand queryNode
+	regex   *regexp.Regexp
+	negate  bool
+}
+
+func (n *regexNode) typ() queryType { return queryBool }
+func (n *regexNode) eval(e ICSEvent) any {
+	matched := false
+	switch value := n.operand.eval(e).(type) {
+	case string:
+		matched = n.regex.MatchString(value)
+	case []string:
+		for _, item := range value {
+			if n.regex.MatchString(item) {
+				matched = true
+				break
+			}
+		}
+	}
+	return matched != n.negate
+}
+
+type arithmeticNode struct {
+	t           queryType
+	subtract    bool
+	left, right queryNode
+}
+
+func newArithmeticNode(op queryToken, left queryNode, right queryNode) (queryNode, error) {
+	node := &arithmeticNode{subtract: op.text == "-", left: left, right: right}
+
+	switch {
+	case left.typ() == queryTime && right.typ() == queryDuration:
+		node.t = queryTime
+	case left.typ() == queryTime && right.typ() == queryTime && node.subtract:
+		node.t = queryDuration
+	case left.typ() == right.typ() && (left.typ() == queryDuration || left.typ() == queryNumber):
+		node.t = left.typ()
+	default:
+		return nil, &QueryError{Column: op.column, Message: fmt.Sprintf("can not use '%s' with a %s and a %s", op.text, left.typ(), right.typ())}
+	}
+	return node, nil
+}
+
+func (n *arithmeticNode) typ() queryType { return n.t }
+func (n *arithmeticNode) eval(e ICSEvent) any {
+	left, right := n.left.eval(e), n.right.eval(e)
+
+	switch left := left.(type) {
+	case time.Time:
+		if t, ok := right.(time.Time); ok {
+			return left.Sub(t)
+		}
+		if n.subtract {
+			return left.Add(-right.(time.Duration))
+		}
+		return left.Add(right.(time.Duration))
+	case time.Duration:
+		if n.subtract {
+			return left - right.(time.Duration)
+		}
+		return left + right.(time.Duration)
+	case int64:
+		if n.subtract {
+			return left - right.(int64)
+		}
+		return left + right.(int64)
+	}
+	return nil
+}
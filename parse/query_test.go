@@ -0,0 +1,214 @@
+package parse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Event queried in the tests, the day after testNow
+func queryTestEvent(t *testing.T) ICSEvent {
+	t.Helper()
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	return ICSEvent{
+		Summary:        "Release Planning",
+		Description:    "Agenda: scope, dates",
+		Location:       "Room 4",
+		Status:         "CONFIRMED",
+		Organizer:      "Alice Smith",
+		Attendees:      []string{"Alice Smith", "bob@example.com"},
+		AttendeeEmails: []string{"alice@example.com", "bob@example.com"},
+		Categories:     []string{"ops", "Planning"},
+		UID:            "planning@example.com",
+		Calendar:       "Work",
+		Start:          time.Date(2024, 9, 12, 10, 0, 0, 0, berlin),
+		End:            time.Date(2024, 9, 12, 11, 30, 0, 0, berlin),
+		Sequence:       2,
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		// Strings
+		{`summary == "Release Planning"`, true},
+		{`summary == 'Release Planning'`, true},
+		{`summary != "Release"`, true},
+		{`summary < "S" && summary >= "Release"`, true},
+		{`summary ~ "^Release"`, true},
+		{`summary ~ "^planning"`, false},
+		{`summary ~ "(?i)planning$"`, true},
+		{`summary !~ "Retro"`, true},
+		{`"Plan" in summary`, true},
+		{`"plan" in summary`, false},
+		{`summary contains "plan"`, true},
+		{`location contains "ROOM"`, true},
+		{`description contains "scope, dates"`, true},
+		{`"\"" in summary`, false},
+		{`url == ""`, true},
+		{`status in ["CONFIRMED", "TENTATIVE"]`, true},
+		{`status in ["confirmed"]`, false},
+
+		// Numbers
+		{`days == 1`, true},
+		{`sequence > 1`, true},
+		{`sequence + 1 == 3`, true},
+		{`sequence - 2 <= 0`, true},
+		{`sequence != 2`, false},
+
+		// Times, now is Wednesday 2024-09-11 14:30 in Berlin
+		{`start > now`, true},
+		{`start < now + 1d`, true},
+		{`start - 1d < now`, true},
+		{`start >= today + 1d && start < today + 2d`, true},
+		{`start == 2024-09-12T08:00:00Z`, true},
+		{`start == 2024-09-12T10:00`, true},
+		{`start >= 2024-09-12`, true},
+		{`end - 30m == 2024-09-12T11:00`, true},
+		{`start >= date("2024-W37") && start < date("2024-W38")`, true},
+		{`start < date("2024-W37") + 1w`, true},
+		{`start >= date("tomorrow") && start < date("+2d")`, true},
+		{`date("next monday") > start`, true},
+		{`start > date('this-week') + 4d`, false},
+		{`end - start == 90m`, true},
+		{`now - start < 0s`, true},
+
+		// Durations
+		{`duration == 90m`, true},
+		{`duration == 1h30m`, true},
+		{`duration > 1h`, true},
+		{`duration + 30m == 2h`, true},
+		{`duration - 1h < 1h`, true},
+		{`duration < 1d`, true},
+		{`duration >= 1w`, false},
+		{`duration > 5400000ms`, false},
+
+		// Lists
+		{`"ops" in categories`, true},
+		{`"OPS" in categories`, false},
+		{`categories contains "OPS"`, true},
+		{`categories contains "op"`, false},
+		{`categories ~ "^Plan"`, true},
+		{`categories !~ "^x"`, true},
+		{`"alice@example.com" in attendees`, true},
+		{`"Alice Smith" in attendees`, true},
+		{`attendees contains "BOB@example.com"`, true},
+		{`attendees ~ "@example\\.com$"`, true},
+		{`"ops" in []`, false},
+		{`"ops" in ["dev", "ops"]`, true},
+
+		// True/false values
+		{`!allday`, true},
+		{`allday == false`, true},
+		{`recurring != true`, true},
+		{`allday || sequence == 2`, true},
+		{`!(allday && true)`, true},
+		{`! !allday`, false},
+		{`false && false || true`, true},
+		{`true || false && false`, true},
+		{`(true || false) && false`, false},
+	}
+
+	event := queryTestEvent(t)
+	now := testNow(t)
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			query, err := ParseQuery(test.query, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query.Source != test.query {
+				t.Errorf("got source %q, want %q", query.Source, test.query)
+			}
+			if got := query.Matches(event); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		query   string
+		column  int
+		message string
+	}{
+		{`summry == "x"`, 1, "unknown field 'summry' (available: " + strings.Join(QueryFields(), ", ") + ")"},
+		{`"é" == summry`, 8, "unknown field 'summry' (available: " + strings.Join(QueryFields(), ", ") + ")"},
+		{`summary < 5`, 9, "can not use '<' with a string and a number"},
+		{`categories == "x"`, 12, "can not use '==' with a list and a string"},
+		{`allday > false`, 8, "can not use '>' with a true/false value and a true/false value"},
+		{`"ops" in sequence`, 7, "can not use 'in' with a string and a number"},
+		{`!(start)`, 1, "'!' needs a true/false value, not a time"},
+		{`allday true`, 8, "unexpected 'true'"},
+		{`allday)`, 7, "unexpected ')'"},
+		{`(allday`, 8, "expected ')' at the end of the query"},
+		{`summary ==`, 11, "expected a value at the end of the query"},
+		{`summary == )`, 12, "expected a value, not ')'"},
+		{`summary == "x`, 12, "unterminated string"},
+		{`summary == @`, 12, "unexpected character '@'"},
+		{`duration > 5x`, 12, "invalid number or duration '5x' (durations use ms, s, m, h, d or w)"},
+		{`summary ~ summary`, 11, "'~' needs a regex in quotes"},
+		{`duration ~ "x"`, 10, "'~' needs a string or list, not a duration"},
+		{`1 < 2 < 3`, 7, "comparisons can not be chained, use '&&'"},
+		{`summary`, 1, "the query must be true or false, not a string"},
+		{`"a" in ["b", 1]`, 14, "lists can only contain strings, not a number"},
+		{`["a"`, 5, "expected ',' at the end of the query"},
+		{`allday && duration`, 8, "'&&' needs true/false values, not a duration"},
+		{`duration || allday`, 10, "'||' needs true/false values, not a duration"},
+		{`start + start > now`, 7, "can not use '+' with a time and a time"},
+		{`duration - start > 1h`, 10, "can not use '-' with a duration and a time"},
+		{`start > date("someday")`, 14, "invalid date 'someday'"},
+		{`start > date(today)`, 14, "date() needs a date in quotes"},
+		{`start > date "today"`, 14, "expected '('"},
+		{`start > 2024-13-01`, 9, "invalid date '2024-13-01'"},
+		{``, 1, "expected a value at the end of the query"},
+	}
+
+	now := testNow(t)
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			_, err := ParseQuery(test.query, now)
+
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("got error %v, want a QueryError", err)
+			}
+			if queryErr.Column != test.column || queryErr.Message != test.message {
+				t.Errorf("got %q at column %d, want %q at column %d", queryErr.Message, queryErr.Column, test.message, test.column)
+			}
+		})
+	}
+}
+
+func TestQueryRegexError(t *testing.T) {
+	_, err := ParseQuery(`summary ~ "("`, testNow(t))
+
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("got error %v, want a QueryError", err)
+	}
+	if queryErr.Column != 11 || !strings.HasPrefix(queryErr.Message, "invalid regex: ") {
+		t.Errorf("got %q at column %d, want an invalid regex at column 11", queryErr.Message, queryErr.Column)
+	}
+	if want := queryErr.Message + " (column 11)"; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
+func TestQueryDefaultNow(t *testing.T) {
+	// A zero 'now' is the current time
+	event := ICSEvent{Start: time.Now()}
+	query, err := ParseQuery(`start <= now && start > now - 1h`, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !query.Matches(event) {
+		t.Errorf("an event starting before the query was parsed is not before 'now'")
+	}
+}
@@ -0,0 +1,254 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/samber/lo"
+)
+
+// Single instance of a recurring event
+type recurrence struct {
+	Start time.Time
+
+	// Explicit end time, only set for RDATE periods
+	End time.Time
+}
+
+// Event replacing an instance of a recurring series (has a RECURRENCE-ID)
+type recurrenceOverride struct {
+	RecurrenceID time.Time
+	IsDate       bool
+
+	// RANGE=THISANDFUTURE, applies to all following instances
+	ThisAndFuture bool
+
+	Cancelled bool
+	Event     ICSEvent
+	used      bool
+}
+
+// Instances of a recurring event up to 'limit', built from its RRULE
+// and RDATE properties, minus any EXDATE and EXRULE exclusions.
+// Rules skip the instances starting well before 'from', when they can.
+//
+// Returns nil if the event does not recur.
+func expandRecurrences(event *ics.VEvent, tz *timezones, dtstart time.Time, from time.Time, limit time.Time) ([]recurrence, error) {
+	// Series without a valid start can not be expanded
+	if dtstart.IsZero() {
+		return nil, nil
+	}
+
+	starts := []time.Time{dtstart}
+	ends := map[int64]time.Time{}
+	var excluded []time.Time
+	var excludedDates []time.Time
+	isRecurring := false
+
+	for _, prop := range event.Properties {
+		switch prop.IANAToken {
+		case string(ics.ComponentPropertyRrule):
+			isRecurring = true
+
+			rule, err := ParseRRule(prop.Value, dtstart.Location())
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE for event '%s': %v", event.Id(), err)
+			}
+			starts = append(starts, rule.occurrences(dtstart, from, limit, true)...)
+
+		case string(ics.ComponentPropertyRdate):
+			isRecurring = true
+
+			if value, ok := prop.ICalParameters[string(ics.ParameterValue)]; ok && len(value) > 0 && value[0] == "PERIOD" {
+				for _, period := range strings.Split(prop.Value, ",") {
+					start, end, err := parsePeriod(tz, period, prop.ICalParameters)
+					if err != nil {
+						return nil, fmt.Errorf("invalid RDATE for event '%s': %v", event.Id(), err)
+					}
+					starts = append(starts, start)
+					ends[start.UnixNano()] = end
+				}
+				continue
+			}
+
+			times, _, err := tz.parseDateTimeList(prop)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RDATE for event '%s': %v", event.Id(), err)
+			}
+			starts = append(starts, times...)
+
+		case string(ics.ComponentPropertyExdate):
+			times, isDate, err := tz.parseDateTimeList(prop)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EXDATE for event '%s': %v", event.Id(), err)
+			}
+			if isDate {
+				excludedDates = append(excludedDates, times...)
+			} else {
+				excluded = append(excluded, times...)
+			}
+
+		case string(ics.ComponentPropertyExrule):
+			rule, err := ParseRRule(prop.Value, dtstart.Location())
+			if err != nil {
+				return nil, fmt.Errorf("invalid EXRULE for event '%s': %v", event.Id(), err)
+			}
+			excluded = append(excluded, rule.occurrences(dtstart, from, limit, false)...)
+		}
+	}
+
+	if !isRecurring {
+		return nil, nil
+	}
+
+	var recurrences []recurrence
+	for _, start := range uniqueTimes(starts) {
+		if start.After(limit) {
+			continue
+		}
+
+		isExcluded := lo.ContainsBy(excluded, start.Equal) || lo.ContainsBy(excludedDates, func(date time.Time) bool {
+			return sameDate(start, date)
+		})
+		if isExcluded {
+			continue
+		}
+
+		recurrences = append(recurrences, recurrence{
+			Start: start,
+			End:   ends[start.UnixNano()],
+		})
+	}
+
+	return recurrences, nil
+}
+
+// Checks if the series ends, when every RRULE has a COUNT or UNTIL
+// (series made only of RDATEs always do)
+func isFiniteSeries(event *ics.VEvent) bool {
+	for _, prop := range event.Properties {
+		if prop.IANAToken != string(ics.ComponentPropertyRrule) {
+			continue
+		}
+
+		rule, err := ParseRRule(prop.Value, time.UTC)
+		if err != nil || (rule.Count == 0 && rule.Until.IsZero()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse an RDATE period, either 'start/end' or 'start/duration'
+func parsePeriod(tz *timezones, period string, params map[string][]string) (time.Time, time.Time, error) {
+	startValue, endValue, ok := strings.Cut(period, "/")
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period '%s'", period)
+	}
+
+	start, _, err := tz.parseDateTime(startValue, params)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if strings.HasPrefix(endValue, "P") || strings.HasPrefix(endValue, "+P") || strings.HasPrefix(endValue, "-P") {
+		duration, err := parseICSDuration(endValue)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		return start, start.Add(duration), nil
+	}
+
+	end, _, err := tz.parseDateTime(endValue, params)
+	return start, end, err
+}
+
+func newRecurrenceOverride(event *ics.VEvent, tz *timezones, icsEvent ICSEvent) (*recurrenceOverride, error) {
+	prop := event.GetProperty(ics.ComponentProperty(ics.PropertyRecurrenceId))
+
+	recurrenceID, isDate, err := tz.parseDateTime(prop.Value, prop.ICalParameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECURRENCE-ID for event '%s': %v", event.Id(), err)
+	}
+
+	thisAndFuture := false
+	if rangeParam, ok := prop.ICalParameters[string(ics.ParameterRange)]; ok && len(rangeParam) > 0 {
+		thisAndFuture = strings.EqualFold(rangeParam[0], "THISANDFUTURE")
+	}
+
+	cancelled := false
+	if status := event.GetProperty(ics.ComponentPropertyStatus); status != nil {
+		cancelled = strings.EqualFold(status.Value, "CANCELLED")
+	}
+
+	icsEvent.RecurrenceID = recurrenceID
+
+	return &recurrenceOverride{
+		RecurrenceID:  recurrenceID,
+		IsDate:        isDate,
+		ThisAndFuture: thisAndFuture,
+		Cancelled:     cancelled,
+		Event:         icsEvent,
+	}, nil
+}
+
+func (o *recurrenceOverride) matches(start time.Time) bool {
+	if o.IsDate {
+		return sameDate(start, o.RecurrenceID)
+	}
+	return start.Equal(o.RecurrenceID)
+}
+
+// Instance of the series with the override applied, shifting the
+// override by the distance between 'start' and its RECURRENCE-ID
+func (o *recurrenceOverride) instance(start time.Time) ICSEvent {
+	event := o.Event
+	if o.matches(start) {
+		return event
+	}
+
+	event.Start = start.Add(o.Event.Start.Sub(o.RecurrenceID))
+	event.End = event.Start.Add(o.Event.End.Sub(o.Event.Start))
+	if o.Event.AllDay {
+		event.End = event.Start.AddDate(0, 0, o.Event.Days())
+	}
+	return event
+}
+
+// Find the override for an instance starting at 'start'.
+//
+// Exact RECURRENCE-ID matches take priority over the most recent
+// THISANDFUTURE override before 'start'.
+func findOverride(overrides []*recurrenceOverride, start time.Time) *recurrenceOverride {
+	var found *recurrenceOverride
+	for _, override := range overrides {
+		if override.matches(start) {
+			return override
+		}
+		if override.ThisAndFuture && override.RecurrenceID.Before(start) {
+			if found == nil || override.RecurrenceID.After(found.RecurrenceID) {
+				found = override
+			}
+		}
+	}
+	return found
+}
+
+// Sort and remove duplicate times
+func uniqueTimes(times []time.Time) []time.Time {
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	return lo.UniqBy(times, func(t time.Time) int64 {
+		return t.UnixNano()
+	})
+}
+
+// Checks if 't' falls on the same day as 'date', in the timezone of 't'
+func sameDate(t time.Time, date time.Time) bool {
+	return t.Year() == date.Year() && t.Month() == date.Month() && t.Day() == date.Day()
+}
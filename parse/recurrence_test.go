@@ -0,0 +1,429 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// Calendar with one VEVENT per item of 'events' (the lines inside it)
+func testCalendar(events ...string) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nX-WR-TIMEZONE:Europe/Berlin\r\n")
+	for _, event := range events {
+		b.WriteString("BEGIN:VEVENT\r\n")
+		for _, line := range strings.Split(strings.TrimSpace(event), "\n") {
+			b.WriteString(strings.TrimSpace(line) + "\r\n")
+		}
+		b.WriteString("END:VEVENT\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
+
+func testExpand(t *testing.T, event string, limit time.Time) []recurrence {
+	t.Helper()
+
+	calendar, err := ics.ParseCalendar(strings.NewReader(testCalendar(event)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tz := newTimezones(calendar)
+	vEvent := calendar.Events()[0]
+
+	dtstart, _, err := tz.parseTimeProperty(&vEvent.ComponentBase, ics.ComponentPropertyDtStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recurrences, err := expandRecurrences(vEvent, tz, dtstart, time.Time{}, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recurrences
+}
+
+func recurrenceStarts(recurrences []recurrence) []string {
+	starts := make([]string, len(recurrences))
+	for i, r := range recurrences {
+		starts[i] = r.Start.Format(rruleLayout)
+	}
+	return starts
+}
+
+func TestExpandRecurrences(t *testing.T) {
+	limit := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		event string
+		want  []string
+	}{
+		{
+			name: "not recurring",
+			event: `UID:single
+				DTSTART:20240902T090000`,
+			want: nil,
+		},
+		{
+			name: "EXDATE date-times",
+			event: `UID:exdate
+				DTSTART;TZID=Europe/Berlin:20240902T090000
+				RRULE:FREQ=DAILY;COUNT=5
+				EXDATE;TZID=Europe/Berlin:20240903T090000,20240905T090000`,
+			want: []string{"20240902T090000", "20240904T090000", "20240906T090000"},
+		},
+		{
+			name: "EXDATE in UTC",
+			event: `UID:exdate-utc
+				DTSTART;TZID=Europe/Berlin:20240902T090000
+				RRULE:FREQ=DAILY;COUNT=3
+				EXDATE:20240903T070000Z`,
+			want: []string{"20240902T090000", "20240904T090000"},
+		},
+		{
+			name: "EXDATE at another time does not exclude",
+			event: `UID:exdate-time
+				DTSTART;TZID=Europe/Berlin:20240902T090000
+				RRULE:FREQ=DAILY;COUNT=3
+				EXDATE;TZID=Europe/Berlin:20240903T100000`,
+			want: []string{"20240902T090000", "20240903T090000", "20240904T090000"},
+		},
+		{
+			name: "EXDATE dates exclude the whole day",
+			event: `UID:exdate-date
+				DTSTART;TZID=Europe/Berlin:20240902T090000
+				RRULE:FREQ=DAILY;COUNT=3
+				EXDATE;VALUE=DATE:20240903`,
+			want: []string{"20240902T090000", "20240904T090000"},
+		},
+		{
+			name: "EXDATE can exclude DTSTART",
+			event: `UID:exdate-dtstart
+				DTSTART;TZID=Europe/Berlin:19970902T090000
+				RRULE:FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;UNTIL=19990101T000000Z
+				EXDATE;TZID=Europe/Berlin:19970902T090000`,
+			want: []string{"19980213T090000", "19980313T090000", "19981113T090000"},
+		},
+		{
+			name: "RDATE adds instances, and duplicates are removed",
+			event: `UID:rdate
+				DTSTART;TZID=Europe/Berlin:20240902T090000
+				RRULE:FREQ=WEEKLY;COUNT=2
+				RDATE;TZID=Europe/Berlin:20240904T140000,20240909T090000`,
+			want: []string{"20240902T090000", "20240904T140000", "20240909T090000"},
+		},
+		{
+			name: "RDATE without RRULE",
+			event: `UID:rdate-only
+				DTSTART;TZID=Europe/Berlin:20240902T090000
+				RDATE;TZID=Europe/Berlin:20240910T090000`,
+			want: []string{"20240902T090000", "20240910T090000"},
+		},
+		{
+			name: "EXRULE excludes a rule",
+			event: `UID:exrule
+				DTSTART;TZID=Europe/Berlin:20240902T090000
+				RRULE:FREQ=DAILY;COUNT=7
+				EXRULE:FREQ=WEEKLY;BYDAY=SA,SU`,
+			want: []string{"20240902T090000", "20240903T090000", "20240904T090000", "20240905T090000", "20240906T090000"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := recurrenceStarts(testExpand(t, test.event, limit))
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("expandRecurrences()\n got  %v\n want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpandRecurrencesPeriods(t *testing.T) {
+	recurrences := testExpand(t, `UID:period
+		DTSTART;TZID=Europe/Berlin:20240902T090000
+		DTEND;TZID=Europe/Berlin:20240902T100000
+		RDATE;VALUE=PERIOD;TZID=Europe/Berlin:20240903T140000/20240903T170000,20240904T080000/PT30M`,
+		time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	want := []struct{ start, end string }{
+		{"20240902T090000", ""},
+		{"20240903T140000", "20240903T170000"},
+		{"20240904T080000", "20240904T083000"},
+	}
+	if len(recurrences) != len(want) {
+		t.Fatalf("got %d recurrences, want %d", len(recurrences), len(want))
+	}
+	for i, r := range recurrences {
+		end := ""
+		if !r.End.IsZero() {
+			end = r.End.Format(rruleLayout)
+		}
+		if r.Start.Format(rruleLayout) != want[i].start || end != want[i].end {
+			t.Errorf("recurrence %d is %s-%s, want %s-%s", i, r.Start.Format(rruleLayout), end, want[i].start, want[i].end)
+		}
+	}
+}
+
+func TestExpandRecurrencesInvalid(t *testing.T) {
+	calendar, err := ics.ParseCalendar(strings.NewReader(testCalendar(`UID:invalid
+		DTSTART:20240902T090000
+		RRULE:FREQ=SOMETIMES`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tz := newTimezones(calendar)
+	_, err = expandRecurrences(calendar.Events()[0], tz, time.Date(2024, 9, 2, 9, 0, 0, 0, time.UTC), time.Time{}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err == nil || !strings.Contains(err.Error(), "invalid RRULE for event 'invalid'") {
+		t.Errorf("got error %v, want an invalid RRULE error", err)
+	}
+}
+
+// Summary and start of each event, e.g. 'Standup 20240902T090000'
+func eventsSummary(events []ICSEvent) []string {
+	summary := make([]string, len(events))
+	for i, e := range events {
+		summary[i] = e.Summary + " " + e.Start.Format(rruleLayout)
+	}
+	return summary
+}
+
+func testParseEvents(t *testing.T, opts ICSParseOptions, events ...string) []ICSEvent {
+	t.Helper()
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	if opts.Timezone == nil {
+		opts.Timezone = berlin
+	}
+	if opts.Start.IsZero() && opts.End.IsZero() {
+		opts.Start = time.Date(2024, 1, 1, 0, 0, 0, 0, berlin)
+		opts.End = time.Date(2025, 1, 1, 0, 0, 0, 0, berlin)
+	}
+
+	calendar, err := IcsToCalendar([]byte(testCalendar(events...)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calendar.Events
+}
+
+func TestRecurrenceOverrides(t *testing.T) {
+	series := `UID:standup
+		SUMMARY:Standup
+		DTSTART;TZID=Europe/Berlin:20240902T090000
+		DTEND;TZID=Europe/Berlin:20240902T091500
+		RRULE:FREQ=DAILY;COUNT=5`
+
+	tests := []struct {
+		name      string
+		overrides []string
+		want      []string
+	}{
+		{
+			name: "moved instance",
+			overrides: []string{`UID:standup
+				SUMMARY:Standup (moved)
+				RECURRENCE-ID;TZID=Europe/Berlin:20240903T090000
+				DTSTART;TZID=Europe/Berlin:20240903T140000
+				DTEND;TZID=Europe/Berlin:20240903T141500`},
+			want: []string{"Standup 20240902T090000", "Standup (moved) 20240903T140000", "Standup 20240904T090000", "Standup 20240905T090000", "Standup 20240906T090000"},
+		},
+		{
+			name: "cancelled instance",
+			overrides: []string{`UID:standup
+				SUMMARY:Standup
+				STATUS:CANCELLED
+				RECURRENCE-ID;TZID=Europe/Berlin:20240904T090000
+				DTSTART;TZID=Europe/Berlin:20240904T090000`},
+			want: []string{"Standup 20240902T090000", "Standup 20240903T090000", "Standup 20240905T090000", "Standup 20240906T090000"},
+		},
+		{
+			name: "override by date",
+			overrides: []string{`UID:standup
+				SUMMARY:Standup (remote)
+				RECURRENCE-ID;VALUE=DATE:20240905
+				DTSTART;TZID=Europe/Berlin:20240905T090000
+				DTEND;TZID=Europe/Berlin:20240905T091500`},
+			want: []string{"Standup 20240902T090000", "Standup 20240903T090000", "Standup 20240904T090000", "Standup (remote) 20240905T090000", "Standup 20240906T090000"},
+		},
+		{
+			name: "THISANDFUTURE moves every following instance",
+			overrides: []string{`UID:standup
+				SUMMARY:Standup (later)
+				RECURRENCE-ID;RANGE=THISANDFUTURE;TZID=Europe/Berlin:20240904T090000
+				DTSTART;TZID=Europe/Berlin:20240904T100000
+				DTEND;TZID=Europe/Berlin:20240904T101500`},
+			want: []string{"Standup 20240902T090000", "Standup 20240903T090000", "Standup (later) 20240904T100000", "Standup (later) 20240905T100000", "Standup (later) 20240906T100000"},
+		},
+		{
+			name: "exact override takes priority over THISANDFUTURE",
+			overrides: []string{
+				`UID:standup
+				SUMMARY:Standup (later)
+				RECURRENCE-ID;RANGE=THISANDFUTURE;TZID=Europe/Berlin:20240903T090000
+				DTSTART;TZID=Europe/Berlin:20240903T100000
+				DTEND;TZID=Europe/Berlin:20240903T101500`,
+				`UID:standup
+				SUMMARY:Standup (offsite)
+				RECURRENCE-ID;TZID=Europe/Berlin:20240905T090000
+				DTSTART;TZID=Europe/Berlin:20240905T130000
+				DTEND;TZID=Europe/Berlin:20240905T140000`,
+			},
+			want: []string{"Standup 20240902T090000", "Standup (later) 20240903T100000", "Standup (later) 20240904T100000", "Standup (offsite) 20240905T130000", "Standup (later) 20240906T100000"},
+		},
+		{
+			name: "override without a matching instance is kept",
+			overrides: []string{`UID:standup
+				SUMMARY:Standup (extra)
+				RECURRENCE-ID;TZID=Europe/Berlin:20240910T090000
+				DTSTART;TZID=Europe/Berlin:20240910T090000
+				DTEND;TZID=Europe/Berlin:20240910T091500`},
+			want: []string{"Standup 20240902T090000", "Standup 20240903T090000", "Standup 20240904T090000", "Standup 20240905T090000", "Standup 20240906T090000", "Standup (extra) 20240910T090000"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := testParseEvents(t, ICSParseOptions{}, append([]string{series}, test.overrides...)...)
+
+			got := eventsSummary(events)
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("events\n got  %v\n want %v", got, test.want)
+			}
+
+			for _, e := range events {
+				if e.RecurrenceID.IsZero() {
+					t.Errorf("event %s has no RecurrenceID", e.Summary)
+				}
+			}
+		})
+	}
+}
+
+func TestFindOverride(t *testing.T) {
+	at := func(day int) time.Time {
+		return time.Date(2024, 9, day, 9, 0, 0, 0, time.UTC)
+	}
+
+	exact := &recurrenceOverride{RecurrenceID: at(5)}
+	future3 := &recurrenceOverride{RecurrenceID: at(3), ThisAndFuture: true}
+	future7 := &recurrenceOverride{RecurrenceID: at(7), ThisAndFuture: true}
+	overrides := []*recurrenceOverride{future7, exact, future3}
+
+	tests := []struct {
+		start time.Time
+		want  *recurrenceOverride
+	}{
+		{at(2), nil},
+		{at(3), future3},
+		{at(4), future3},
+		{at(5), exact},
+		{at(6), future3},
+		{at(8), future7},
+	}
+
+	for _, test := range tests {
+		if got := findOverride(overrides, test.start); got != test.want {
+			t.Errorf("findOverride(%s) = %+v, want %+v", test.start.Format(rruleLayout), got, test.want)
+		}
+	}
+}
+
+func TestRecurrenceDefaultWindow(t *testing.T) {
+	weekly := `UID:weekly
+		SUMMARY:Weekly
+		DTSTART;TZID=Europe/Berlin:20100104T090000
+		DTEND;TZID=Europe/Berlin:20100104T100000
+		RRULE:FREQ=WEEKLY`
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	now := time.Date(2024, 9, 2, 12, 0, 0, 0, berlin)
+
+	calendar, err := IcsToCalendar([]byte(testCalendar(weekly)), ICSParseOptions{Now: now, Horizon: 28 * 24 * time.Hour, Timezone: berlin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Four weeks either side of now, not every week since 2010
+	got := eventsSummary(calendar.Events)
+	want := []string{
+		"Weekly 20240812T090000", "Weekly 20240819T090000", "Weekly 20240826T090000", "Weekly 20240902T090000",
+		"Weekly 20240909T090000", "Weekly 20240916T090000", "Weekly 20240923T090000", "Weekly 20240930T090000",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events\n got  %v\n want %v", got, want)
+	}
+
+	// An explicit start includes older instances
+	calendar, err = IcsToCalendar([]byte(testCalendar(weekly)), ICSParseOptions{Start: time.Date(2010, 1, 1, 0, 0, 0, 0, berlin), End: time.Date(2010, 2, 1, 0, 0, 0, 0, berlin), Timezone: berlin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendar.Events) != 4 {
+		t.Errorf("got %d events in January 2010, want 4", len(calendar.Events))
+	}
+}
+
+func TestRecurrenceFiniteSeries(t *testing.T) {
+	weekly := `UID:weekly
+		SUMMARY:Weekly
+		DTSTART;TZID=Europe/Berlin:20240902T090000
+		DTEND;TZID=Europe/Berlin:20240902T100000
+		RRULE:FREQ=WEEKLY;COUNT=6`
+	until := `UID:until
+		SUMMARY:Until
+		DTSTART;TZID=Europe/Berlin:20210104T090000
+		RRULE:FREQ=MONTHLY;UNTIL=20210401T000000Z`
+	once := `UID:once
+		SUMMARY:Once
+		DTSTART;TZID=Europe/Berlin:20210301T120000`
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, berlin)
+
+	// Without a window, series which end are listed in full, like
+	// events which do not recur
+	calendar, err := IcsToCalendar([]byte(testCalendar(weekly, until, once)), ICSParseOptions{Now: now, Timezone: berlin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := eventsSummary(calendar.Events)
+	want := []string{
+		"Until 20210104T090000", "Until 20210204T090000", "Once 20210301T120000", "Until 20210304T090000",
+		"Weekly 20240902T090000", "Weekly 20240909T090000", "Weekly 20240916T090000",
+		"Weekly 20240923T090000", "Weekly 20240930T090000", "Weekly 20241007T090000",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events\n got  %v\n want %v", got, want)
+	}
+
+	// A window still applies to them
+	calendar, err = IcsToCalendar([]byte(testCalendar(weekly)), ICSParseOptions{Start: time.Date(2024, 9, 20, 0, 0, 0, 0, berlin), End: time.Date(2024, 10, 1, 0, 0, 0, 0, berlin), Timezone: berlin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventsSummary(calendar.Events); strings.Join(got, ",") != "Weekly 20240923T090000,Weekly 20240930T090000" {
+		t.Errorf("got %v in the window, want the 23rd and 30th", got)
+	}
+}
+
+// Instances starting before the window, which end in it, are kept
+func TestRecurrenceWindowOverlap(t *testing.T) {
+	daily := `UID:daily
+		SUMMARY:Daily
+		DTSTART;TZID=Europe/Berlin:20200101T200000
+		DTEND;TZID=Europe/Berlin:20200102T080000
+		RRULE:FREQ=DAILY`
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	events := testParseEvents(t, ICSParseOptions{Start: time.Date(2024, 9, 2, 0, 0, 0, 0, berlin), End: time.Date(2024, 9, 3, 0, 0, 0, 0, berlin)}, daily)
+
+	if got := eventsSummary(events); strings.Join(got, ",") != "Daily 20240901T200000,Daily 20240902T200000" {
+		t.Errorf("got %v, want the instances of the 1st and 2nd", got)
+	}
+}
@@ -0,0 +1,603 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequency (RFC 5545 FREQ), ordered from smallest to largest
+type Frequency int
+
+const (
+	FrequencySecondly Frequency = iota
+	FrequencyMinutely
+	FrequencyHourly
+	FrequencyDaily
+	FrequencyWeekly
+	FrequencyMonthly
+	FrequencyYearly
+)
+
+var frequencies = map[string]Frequency{
+	"SECONDLY": FrequencySecondly,
+	"MINUTELY": FrequencyMinutely,
+	"HOURLY":   FrequencyHourly,
+	"DAILY":    FrequencyDaily,
+	"WEEKLY":   FrequencyWeekly,
+	"MONTHLY":  FrequencyMonthly,
+	"YEARLY":   FrequencyYearly,
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Most instances generated for a single rule, and most periods
+// searched for them, which bounds rules that (nearly) never match
+const (
+	maxRecurrences       = 100000
+	maxRecurrencePeriods = 10 * maxRecurrences
+)
+
+// Weekday with an optional ordinal, as used in BYDAY (e.g. '-1FR')
+//
+// N is zero when no ordinal was given.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// Parsed RFC 5545 recurrence rule
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	BySecond   []int
+	ByMinute   []int
+	ByHour     []int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByYearDay  []int
+	ByWeekNo   []int
+	ByMonth    []int
+	BySetPos   []int
+	Wkst       time.Weekday
+}
+
+// Parse an RRULE value (e.g. 'FREQ=WEEKLY;BYDAY=MO,WE').
+//
+// A floating or date-only UNTIL is interpreted in 'loc'.
+func ParseRRule(value string, loc *time.Location) (*RRule, error) {
+	rule := &RRule{
+		Interval: 1,
+		Wkst:     time.Monday,
+	}
+	hasFreq := false
+
+	for _, part := range strings.Split(strings.TrimSpace(value), ";") {
+		if part == "" {
+			continue
+		}
+
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part '%s'", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq, ok = frequencies[strings.ToUpper(val)]
+			if !ok {
+				return nil, fmt.Errorf("unknown FREQ '%s'", val)
+			}
+			hasFreq = true
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("must be a positive integer")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+			if err == nil && rule.Count < 1 {
+				err = fmt.Errorf("must be a positive integer")
+			}
+		case "UNTIL":
+			rule.Until, err = parseUntil(val, loc)
+		case "BYSECOND":
+			rule.BySecond, err = parseIntList(val, 0, 60, false)
+		case "BYMINUTE":
+			rule.ByMinute, err = parseIntList(val, 0, 59, false)
+		case "BYHOUR":
+			rule.ByHour, err = parseIntList(val, 0, 23, false)
+		case "BYDAY":
+			rule.ByDay, err = parseWeekdayList(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(val, 1, 31, true)
+		case "BYYEARDAY":
+			rule.ByYearDay, err = parseIntList(val, 1, 366, true)
+		case "BYWEEKNO":
+			rule.ByWeekNo, err = parseIntList(val, 1, 53, true)
+		case "BYMONTH":
+			rule.ByMonth, err = parseIntList(val, 1, 12, false)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(val, 1, 366, true)
+		case "WKST":
+			rule.Wkst, ok = weekdays[strings.ToUpper(val)]
+			if !ok {
+				err = fmt.Errorf("unknown weekday")
+			}
+		default:
+			// Ignore unknown (x-name) rule parts
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", strings.ToUpper(name), val, err)
+		}
+	}
+
+	if !hasFreq {
+		return nil, fmt.Errorf("rule '%s' has no FREQ", value)
+	}
+
+	return rule, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		return time.ParseInLocation("20060102T150405Z", value, time.UTC)
+	case strings.Contains(value, "T"):
+		return time.ParseInLocation("20060102T150405", value, loc)
+	}
+
+	// Date-only values include the whole day
+	date, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return date, err
+	}
+	return date.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+func parseIntList(value string, min int, max int, allowNegative bool) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+
+		abs := n
+		if allowNegative && n < 0 {
+			abs = -n
+		}
+		if abs < min || abs > max {
+			return nil, fmt.Errorf("%d is out of range", n)
+		}
+
+		list = append(list, n)
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
+func parseWeekdayList(value string) ([]WeekdayNum, error) {
+	var list []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if len(item) < 2 {
+			return nil, fmt.Errorf("unknown weekday '%s'", item)
+		}
+
+		weekday, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday '%s'", item)
+		}
+
+		n := 0
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			var err error
+			n, err = strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid ordinal '%s'", ordinal)
+			}
+		}
+
+		list = append(list, WeekdayNum{N: n, Weekday: weekday})
+	}
+	return list, nil
+}
+
+// Occurrences returns the start time of each instance of the rule, from
+// 'dtstart' up to (and including) 'limit'.
+//
+// As per RFC 5545, 'dtstart' is always the first instance, and counts
+// towards COUNT, even when it does not match the rule itself.
+func (r *RRule) Occurrences(dtstart time.Time, limit time.Time) []time.Time {
+	return r.occurrences(dtstart, time.Time{}, limit, true)
+}
+
+// Instances of the rule, 'dtstart' is only included when it matches
+// the rule unless 'withStart' is set (EXRULE only excludes matches).
+//
+// Without a COUNT, periods before 'from' are skipped, though a few
+// instances before it may still be returned.
+func (r *RRule) occurrences(dtstart time.Time, from time.Time, limit time.Time, withStart bool) []time.Time {
+	var occurrences []time.Time
+	if withStart {
+		occurrences = append(occurrences, dtstart)
+		if r.Count == 1 {
+			return occurrences
+		}
+	}
+
+	loc := dtstart.Location()
+	rule := r.withDefaults(dtstart)
+	start := toWallClock(dtstart)
+
+	// Add candidates from a single period, returns false once done
+	emit := func(candidates []time.Time) bool {
+		for _, wall := range rule.applySetPos(candidates) {
+			if wall.Before(start) {
+				continue
+			}
+
+			occurrence := fromWallClock(wall, loc)
+			if occurrence.After(limit) || (!rule.Until.IsZero() && occurrence.After(rule.Until)) {
+				return false
+			}
+			if withStart && occurrence.Equal(dtstart) {
+				continue
+			}
+
+			occurrences = append(occurrences, occurrence)
+			if (rule.Count > 0 && len(occurrences) >= rule.Count) || len(occurrences) >= maxRecurrences {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Stop once a period begins after the limit, this also
+	// bounds rules which can never produce an instance
+	periods := 0
+	pastLimit := func(periodStart time.Time) bool {
+		periods++
+		periodStart = fromWallClock(periodStart, loc)
+		return periodStart.After(limit) || (!rule.Until.IsZero() && periodStart.After(rule.Until)) || periods > maxRecurrencePeriods
+	}
+
+	// Instances are counted from 'dtstart', so only rules without a
+	// COUNT can skip ahead. One period is kept as a margin for
+	// daylight saving time.
+	skip := rule.Count == 0 && from.After(dtstart)
+	fromWall := toWallClock(from.In(loc))
+
+	if rule.Freq >= FrequencyDaily {
+		first := 0
+		if skip {
+			first = max(rule.periodsBetween(start, fromWall)/rule.Interval-1, 0) * rule.Interval
+		}
+
+		for n := first; ; n += rule.Interval {
+			periodStart, days := rule.periodDays(start, n)
+			if pastLimit(periodStart) {
+				break
+			}
+
+			var candidates []time.Time
+			for _, day := range days {
+				if !rule.matchDay(day) {
+					continue
+				}
+				candidates = append(candidates, rule.timeSet(day)...)
+			}
+
+			if !emit(candidates) {
+				break
+			}
+		}
+
+		return occurrences
+	}
+
+	var step time.Duration
+	switch rule.Freq {
+	case FrequencyHourly:
+		step = time.Hour
+	case FrequencyMinutely:
+		step = time.Minute
+	default:
+		step = time.Second
+	}
+
+	first := start.Truncate(step)
+	step *= time.Duration(rule.Interval)
+	if skip && fromWall.After(first) {
+		first = first.Add(step * max(fromWall.Sub(first)/step-1, 0))
+	}
+
+	for period := first; !pastLimit(period); period = period.Add(step) {
+		if !rule.matchDay(period) {
+			// Skip ahead to the first period of the next day
+			nextDay := time.Date(period.Year(), period.Month(), period.Day()+1, 0, 0, 0, 0, time.UTC)
+			skip := (nextDay.Sub(period) + step - 1) / step
+			period = period.Add(step * (skip - 1))
+			continue
+		}
+		if !rule.matchTime(period) {
+			continue
+		}
+
+		if !emit(rule.timeSet(period)) {
+			break
+		}
+	}
+
+	return occurrences
+}
+
+// Copy of the rule with the BYxxx defaults derived from 'dtstart'
+func (r *RRule) withDefaults(dtstart time.Time) *RRule {
+	rule := *r
+	if rule.Interval < 1 {
+		rule.Interval = 1
+	}
+
+	if len(rule.ByWeekNo) == 0 && len(rule.ByYearDay) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+		switch rule.Freq {
+		case FrequencyYearly:
+			if len(rule.ByMonth) == 0 {
+				rule.ByMonth = []int{int(dtstart.Month())}
+			}
+			rule.ByMonthDay = []int{dtstart.Day()}
+		case FrequencyMonthly:
+			rule.ByMonthDay = []int{dtstart.Day()}
+		case FrequencyWeekly:
+			rule.ByDay = []WeekdayNum{{Weekday: dtstart.Weekday()}}
+		}
+	}
+
+	if len(rule.ByHour) == 0 && rule.Freq > FrequencyHourly {
+		rule.ByHour = []int{dtstart.Hour()}
+	}
+	if len(rule.ByMinute) == 0 && rule.Freq > FrequencyMinutely {
+		rule.ByMinute = []int{dtstart.Minute()}
+	}
+	if len(rule.BySecond) == 0 && rule.Freq > FrequencySecondly {
+		rule.BySecond = []int{dtstart.Second()}
+	}
+
+	return &rule
+}
+
+// First day and all days, of the n-th period after 'start'
+func (r *RRule) periodDays(start time.Time, n int) (time.Time, []time.Time) {
+	var first time.Time
+	var length int
+
+	switch r.Freq {
+	case FrequencyYearly:
+		first = time.Date(start.Year()+n, 1, 1, 0, 0, 0, 0, time.UTC)
+		length = daysInYear(first.Year())
+	case FrequencyMonthly:
+		first = time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+		length = daysInMonth(first.Year(), first.Month())
+	case FrequencyWeekly:
+		offset := (int(start.Weekday()) - int(r.Wkst) + 7) % 7
+		first = time.Date(start.Year(), start.Month(), start.Day()-offset+7*n, 0, 0, 0, 0, time.UTC)
+		length = 7
+	default:
+		first = time.Date(start.Year(), start.Month(), start.Day()+n, 0, 0, 0, 0, time.UTC)
+		length = 1
+	}
+
+	days := make([]time.Time, length)
+	for i := range days {
+		days[i] = first.AddDate(0, 0, i)
+	}
+	return first, days
+}
+
+// Number of whole periods (of a daily or longer frequency) from the
+// period of 'start' to the period of 't'
+func (r *RRule) periodsBetween(start time.Time, t time.Time) int {
+	switch r.Freq {
+	case FrequencyYearly:
+		return t.Year() - start.Year()
+	case FrequencyMonthly:
+		return (t.Year()-start.Year())*12 + int(t.Month()) - int(start.Month())
+	}
+
+	firstDay, _ := r.periodDays(start, 0)
+	days := int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Sub(firstDay).Hours() / 24)
+	if r.Freq == FrequencyWeekly {
+		return days / 7
+	}
+	return days
+}
+
+// Checks the day of 't' against all day-level BYxxx parts
+func (r *RRule) matchDay(t time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(t.Month())) {
+		return false
+	}
+
+	if len(r.ByWeekNo) > 0 {
+		weekNo, weeks := weekNumber(t, r.Wkst)
+		if !containsInt(r.ByWeekNo, weekNo) && !containsInt(r.ByWeekNo, weekNo-weeks-1) {
+			return false
+		}
+	}
+
+	if len(r.ByYearDay) > 0 {
+		yearDay := t.YearDay()
+		if !containsInt(r.ByYearDay, yearDay) && !containsInt(r.ByYearDay, yearDay-daysInYear(t.Year())-1) {
+			return false
+		}
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		monthDay := t.Day()
+		if !containsInt(r.ByMonthDay, monthDay) && !containsInt(r.ByMonthDay, monthDay-daysInMonth(t.Year(), t.Month())-1) {
+			return false
+		}
+	}
+
+	if len(r.ByDay) > 0 && !r.matchWeekday(t) {
+		return false
+	}
+
+	return true
+}
+
+func (r *RRule) matchWeekday(t time.Time) bool {
+	// Ordinals are relative to the month or year, and are
+	// ignored for any other frequency
+	inMonth := r.Freq == FrequencyMonthly || (r.Freq == FrequencyYearly && len(r.ByMonth) > 0)
+	inYear := r.Freq == FrequencyYearly && len(r.ByMonth) == 0
+
+	for _, wd := range r.ByDay {
+		if wd.Weekday != t.Weekday() {
+			continue
+		}
+		if wd.N == 0 || (!inMonth && !inYear) {
+			return true
+		}
+
+		var index, length int
+		if inMonth {
+			index, length = t.Day(), daysInMonth(t.Year(), t.Month())
+		} else {
+			index, length = t.YearDay(), daysInYear(t.Year())
+		}
+
+		if wd.N == (index-1)/7+1 || wd.N == -((length-index)/7+1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks the time of 't' against BYxxx parts which limit (rather than
+// expand) the instances of sub-daily frequencies
+func (r *RRule) matchTime(t time.Time) bool {
+	if len(r.ByHour) > 0 && !containsInt(r.ByHour, t.Hour()) {
+		return false
+	}
+	if r.Freq <= FrequencyMinutely && len(r.ByMinute) > 0 && !containsInt(r.ByMinute, t.Minute()) {
+		return false
+	}
+	if r.Freq <= FrequencySecondly && len(r.BySecond) > 0 && !containsInt(r.BySecond, t.Second()) {
+		return false
+	}
+	return true
+}
+
+// Expands a day (or sub-daily period) into sorted instance times
+func (r *RRule) timeSet(t time.Time) []time.Time {
+	hours := r.ByHour
+	minutes := r.ByMinute
+	seconds := r.BySecond
+
+	if r.Freq <= FrequencyHourly {
+		hours = []int{t.Hour()}
+	}
+	if r.Freq <= FrequencyMinutely {
+		minutes = []int{t.Minute()}
+	}
+	if r.Freq <= FrequencySecondly {
+		seconds = []int{t.Second()}
+	}
+
+	times := make([]time.Time, 0, len(hours)*len(minutes)*len(seconds))
+	for _, hour := range hours {
+		for _, minute := range minutes {
+			for _, second := range seconds {
+				times = append(times, time.Date(t.Year(), t.Month(), t.Day(), hour, minute, second, 0, time.UTC))
+			}
+		}
+	}
+	return times
+}
+
+func (r *RRule) applySetPos(candidates []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 {
+		return candidates
+	}
+
+	var selected []time.Time
+	for i := range candidates {
+		if containsInt(r.BySetPos, i+1) || containsInt(r.BySetPos, i-len(candidates)) {
+			selected = append(selected, candidates[i])
+		}
+	}
+	return selected
+}
+
+// Week number of 't' and the number of weeks in its week-numbering
+// year, where week 1 is the first week with at least 4 days in the year
+func weekNumber(t time.Time, wkst time.Weekday) (int, int) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	year := day.Year()
+	if day.Before(firstWeekStart(year, wkst)) {
+		year--
+	} else if !day.Before(firstWeekStart(year+1, wkst)) {
+		year++
+	}
+
+	first := firstWeekStart(year, wkst)
+	weekNo := int(day.Sub(first).Hours()/24)/7 + 1
+	weeks := int(firstWeekStart(year+1, wkst).Sub(first).Hours()/24) / 7
+
+	return weekNo, weeks
+}
+
+func firstWeekStart(year int, wkst time.Weekday) time.Time {
+	jan1 := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan1.Weekday()) - int(wkst) + 7) % 7
+	first := jan1.AddDate(0, 0, -offset)
+	if offset > 3 {
+		first = first.AddDate(0, 0, 7)
+	}
+	return first
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func containsInt(list []int, n int) bool {
+	for _, i := range list {
+		if i == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Wall clock reading of 't' stored as UTC, so that date arithmetic
+// is unaffected by daylight saving transitions
+func toWallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+func fromWallClock(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
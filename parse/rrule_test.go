@@ -0,0 +1,571 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+const rruleLayout = "20060102T150405"
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func formatTimes(times []time.Time) []string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format(rruleLayout)
+	}
+	return formatted
+}
+
+// Examples from RFC 5545 section 3.8.5.3, all in America/New_York
+func TestRRuleOccurrencesRFC5545(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart string
+		limit   string
+		want    []string
+	}{
+		{
+			name:    "daily for 10 occurrences",
+			rule:    "FREQ=DAILY;COUNT=10",
+			dtstart: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970903T090000", "19970904T090000", "19970905T090000", "19970906T090000",
+				"19970907T090000", "19970908T090000", "19970909T090000", "19970910T090000", "19970911T090000",
+			},
+		},
+		{
+			name:    "every other day",
+			rule:    "FREQ=DAILY;INTERVAL=2",
+			dtstart: "19970902T090000",
+			limit:   "19970910T090000",
+			want:    []string{"19970902T090000", "19970904T090000", "19970906T090000", "19970908T090000", "19970910T090000"},
+		},
+		{
+			name:    "every 10 days, 5 occurrences",
+			rule:    "FREQ=DAILY;INTERVAL=10;COUNT=5",
+			dtstart: "19970902T090000",
+			want:    []string{"19970902T090000", "19970912T090000", "19970922T090000", "19971002T090000", "19971012T090000"},
+		},
+		{
+			name:    "weekly for 10 occurrences, across the end of DST",
+			rule:    "FREQ=WEEKLY;COUNT=10",
+			dtstart: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970909T090000", "19970916T090000", "19970923T090000", "19970930T090000",
+				"19971007T090000", "19971014T090000", "19971021T090000", "19971028T090000", "19971104T090000",
+			},
+		},
+		{
+			name:    "every other week on Monday, Wednesday and Friday until December 24",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;UNTIL=19971224T000000Z;WKST=SU;BYDAY=MO,WE,FR",
+			dtstart: "19970901T090000",
+			want: []string{
+				"19970901T090000", "19970903T090000", "19970905T090000", "19970915T090000", "19970917T090000",
+				"19970919T090000", "19970929T090000", "19971001T090000", "19971003T090000", "19971013T090000",
+				"19971015T090000", "19971017T090000", "19971027T090000", "19971029T090000", "19971031T090000",
+				"19971110T090000", "19971112T090000", "19971114T090000", "19971124T090000", "19971126T090000",
+				"19971128T090000", "19971208T090000", "19971210T090000", "19971212T090000", "19971222T090000",
+			},
+		},
+		{
+			name:    "every other week on Tuesday and Thursday, for 8 occurrences",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;COUNT=8;WKST=SU;BYDAY=TU,TH",
+			dtstart: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970904T090000", "19970916T090000", "19970918T090000",
+				"19970930T090000", "19971002T090000", "19971014T090000", "19971016T090000",
+			},
+		},
+		{
+			name:    "monthly on the first Friday for 10 occurrences",
+			rule:    "FREQ=MONTHLY;COUNT=10;BYDAY=1FR",
+			dtstart: "19970905T090000",
+			want: []string{
+				"19970905T090000", "19971003T090000", "19971107T090000", "19971205T090000", "19980102T090000",
+				"19980206T090000", "19980306T090000", "19980403T090000", "19980501T090000", "19980605T090000",
+			},
+		},
+		{
+			name:    "monthly on the first and last Sunday for 10 occurrences",
+			rule:    "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU",
+			dtstart: "19970907T090000",
+			want: []string{
+				"19970907T090000", "19970928T090000", "19971102T090000", "19971130T090000", "19980104T090000",
+				"19980125T090000", "19980301T090000", "19980329T090000", "19980503T090000", "19980531T090000",
+			},
+		},
+		{
+			name:    "monthly on the second-to-last Monday for 6 months",
+			rule:    "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO",
+			dtstart: "19970922T090000",
+			want:    []string{"19970922T090000", "19971020T090000", "19971117T090000", "19971222T090000", "19980119T090000", "19980216T090000"},
+		},
+		{
+			name:    "monthly on the third-to-the-last day",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-3",
+			dtstart: "19970928T090000",
+			limit:   "19980226T090000",
+			want:    []string{"19970928T090000", "19971029T090000", "19971128T090000", "19971229T090000", "19980129T090000", "19980226T090000"},
+		},
+		{
+			name:    "monthly on the 2nd and 15th for 10 occurrences",
+			rule:    "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=2,15",
+			dtstart: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970915T090000", "19971002T090000", "19971015T090000", "19971102T090000",
+				"19971115T090000", "19971202T090000", "19971215T090000", "19980102T090000", "19980115T090000",
+			},
+		},
+		{
+			name:    "monthly on the first and last day for 10 occurrences",
+			rule:    "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=1,-1",
+			dtstart: "19970930T090000",
+			want: []string{
+				"19970930T090000", "19971001T090000", "19971031T090000", "19971101T090000", "19971130T090000",
+				"19971201T090000", "19971231T090000", "19980101T090000", "19980131T090000", "19980201T090000",
+			},
+		},
+		{
+			name:    "every Tuesday, every other month",
+			rule:    "FREQ=MONTHLY;INTERVAL=2;BYDAY=TU",
+			dtstart: "19970902T090000",
+			limit:   "19980127T090000",
+			want: []string{
+				"19970902T090000", "19970909T090000", "19970916T090000", "19970923T090000", "19970930T090000",
+				"19971104T090000", "19971111T090000", "19971118T090000", "19971125T090000",
+				"19980106T090000", "19980113T090000", "19980120T090000", "19980127T090000",
+			},
+		},
+		{
+			name:    "yearly in June and July for 10 occurrences",
+			rule:    "FREQ=YEARLY;COUNT=10;BYMONTH=6,7",
+			dtstart: "19970610T090000",
+			want: []string{
+				"19970610T090000", "19970710T090000", "19980610T090000", "19980710T090000", "19990610T090000",
+				"19990710T090000", "20000610T090000", "20000710T090000", "20010610T090000", "20010710T090000",
+			},
+		},
+		{
+			name:    "every third year on the 1st, 100th and 200th day for 10 occurrences",
+			rule:    "FREQ=YEARLY;INTERVAL=3;COUNT=10;BYYEARDAY=1,100,200",
+			dtstart: "19970101T090000",
+			want: []string{
+				"19970101T090000", "19970410T090000", "19970719T090000", "20000101T090000", "20000409T090000",
+				"20000718T090000", "20030101T090000", "20030410T090000", "20030719T090000", "20060101T090000",
+			},
+		},
+		{
+			name:    "every 20th Monday of the year",
+			rule:    "FREQ=YEARLY;BYDAY=20MO",
+			dtstart: "19970519T090000",
+			limit:   "19991231T000000",
+			want:    []string{"19970519T090000", "19980518T090000", "19990517T090000"},
+		},
+		{
+			name:    "Monday of week number 20",
+			rule:    "FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO",
+			dtstart: "19970512T090000",
+			limit:   "19991231T000000",
+			want:    []string{"19970512T090000", "19980511T090000", "19990517T090000"},
+		},
+		{
+			name:    "every Thursday in March",
+			rule:    "FREQ=YEARLY;BYMONTH=3;BYDAY=TH",
+			dtstart: "19970313T090000",
+			limit:   "19991231T000000",
+			want: []string{
+				"19970313T090000", "19970320T090000", "19970327T090000",
+				"19980305T090000", "19980312T090000", "19980319T090000", "19980326T090000",
+				"19990304T090000", "19990311T090000", "19990318T090000", "19990325T090000",
+			},
+		},
+		{
+			name:    "every Friday the 13th, after the first instance",
+			rule:    "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			dtstart: "19970902T090000",
+			limit:   "20001231T000000",
+			want:    []string{"19970902T090000", "19980213T090000", "19980313T090000", "19981113T090000", "19990813T090000", "20001013T090000"},
+		},
+		{
+			name:    "first Saturday that follows the first Sunday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=SA;BYMONTHDAY=7,8,9,10,11,12,13",
+			dtstart: "19970913T090000",
+			limit:   "19980613T090000",
+			want: []string{
+				"19970913T090000", "19971011T090000", "19971108T090000", "19971213T090000", "19980110T090000",
+				"19980207T090000", "19980307T090000", "19980411T090000", "19980509T090000", "19980613T090000",
+			},
+		},
+		{
+			name:    "US presidential election day",
+			rule:    "FREQ=YEARLY;INTERVAL=4;BYMONTH=11;BYDAY=TU;BYMONTHDAY=2,3,4,5,6,7,8",
+			dtstart: "19961105T090000",
+			limit:   "20041231T000000",
+			want:    []string{"19961105T090000", "20001107T090000", "20041102T090000"},
+		},
+		{
+			name:    "third Tuesday, Wednesday or Thursday of the month, for 3 occurrences",
+			rule:    "FREQ=MONTHLY;COUNT=3;BYDAY=TU,WE,TH;BYSETPOS=3",
+			dtstart: "19970904T090000",
+			want:    []string{"19970904T090000", "19971007T090000", "19971106T090000"},
+		},
+		{
+			name:    "second-to-last weekday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-2",
+			dtstart: "19970929T090000",
+			limit:   "19980330T090000",
+			want:    []string{"19970929T090000", "19971030T090000", "19971127T090000", "19971230T090000", "19980129T090000", "19980226T090000", "19980330T090000"},
+		},
+		{
+			// The RFC lists 15:00 as well, but its UNTIL (13:00 EDT) excludes it
+			name:    "every 3 hours until 13:00 EDT",
+			rule:    "FREQ=HOURLY;INTERVAL=3;UNTIL=19970902T170000Z",
+			dtstart: "19970902T090000",
+			want:    []string{"19970902T090000", "19970902T120000"},
+		},
+		{
+			name:    "every 15 minutes for 6 occurrences",
+			rule:    "FREQ=MINUTELY;INTERVAL=15;COUNT=6",
+			dtstart: "19970902T090000",
+			want:    []string{"19970902T090000", "19970902T091500", "19970902T093000", "19970902T094500", "19970902T100000", "19970902T101500"},
+		},
+		{
+			name:    "every hour and a half for 4 occurrences",
+			rule:    "FREQ=MINUTELY;INTERVAL=90;COUNT=4",
+			dtstart: "19970902T090000",
+			want:    []string{"19970902T090000", "19970902T103000", "19970902T120000", "19970902T133000"},
+		},
+		{
+			name:    "every 20 minutes from 9:00 to 16:40, daily",
+			rule:    "FREQ=DAILY;BYHOUR=9,10,11,12,13,14,15,16;BYMINUTE=0,20,40",
+			dtstart: "19970902T090000",
+			limit:   "19970902T120000",
+			want: []string{
+				"19970902T090000", "19970902T092000", "19970902T094000", "19970902T100000", "19970902T102000",
+				"19970902T104000", "19970902T110000", "19970902T112000", "19970902T114000", "19970902T120000",
+			},
+		},
+		{
+			name:    "WKST=MO, every other week on Tuesday and Sunday",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			dtstart: "19970805T090000",
+			want:    []string{"19970805T090000", "19970810T090000", "19970819T090000", "19970824T090000"},
+		},
+		{
+			name:    "WKST=SU, every other week on Tuesday and Sunday",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			dtstart: "19970805T090000",
+			want:    []string{"19970805T090000", "19970817T090000", "19970819T090000", "19970831T090000"},
+		},
+		{
+			name:    "the 15th and 30th, skipping February",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=15,30;COUNT=5",
+			dtstart: "20070115T090000",
+			want:    []string{"20070115T090000", "20070130T090000", "20070215T090000", "20070315T090000", "20070330T090000"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.rule, newYork)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", test.rule, err)
+			}
+
+			dtstart, _ := time.ParseInLocation(rruleLayout, test.dtstart, newYork)
+			limit := time.Date(2100, 1, 1, 0, 0, 0, 0, newYork)
+			if test.limit != "" {
+				limit, _ = time.ParseInLocation(rruleLayout, test.limit, newYork)
+			}
+
+			got := formatTimes(rule.Occurrences(dtstart, limit))
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("Occurrences()\n got  %v\n want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRRuleOccurrencesCount(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name      string
+		rule      string
+		dtstart   string
+		wantCount int
+		wantLast  string
+	}{
+		{"daily until December 24", "FREQ=DAILY;UNTIL=19971224T000000Z", "19970902T090000", 113, "19971223T090000"},
+		{"every day in January for 3 years", "FREQ=YEARLY;UNTIL=20000131T140000Z;BYMONTH=1;BYDAY=SU,MO,TU,WE,TH,FR,SA", "19980101T090000", 93, "20000131T090000"},
+		{"the same, daily", "FREQ=DAILY;UNTIL=20000131T140000Z;BYMONTH=1", "19980101T090000", 93, "20000131T090000"},
+		{"weekly until December 24", "FREQ=WEEKLY;UNTIL=19971224T000000Z", "19970902T090000", 17, "19971223T090000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.rule, newYork)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", test.rule, err)
+			}
+
+			dtstart, _ := time.ParseInLocation(rruleLayout, test.dtstart, newYork)
+			got := rule.Occurrences(dtstart, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+
+			if len(got) != test.wantCount {
+				t.Errorf("got %d occurrences, want %d", len(got), test.wantCount)
+			}
+			if last := got[len(got)-1].Format(rruleLayout); last != test.wantLast {
+				t.Errorf("last occurrence is %s, want %s", last, test.wantLast)
+			}
+		})
+	}
+}
+
+func TestRRuleOccurrencesSkipsInvalidDates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart string
+		want    []string
+	}{
+		{"February 29th only in leap years", "FREQ=YEARLY;COUNT=3", "20000229T100000", []string{"20000229T100000", "20040229T100000", "20080229T100000"}},
+		{"the 31st only in long months", "FREQ=MONTHLY;COUNT=4", "20240131T100000", []string{"20240131T100000", "20240331T100000", "20240531T100000", "20240731T100000"}},
+		{"the last day of each month", "FREQ=MONTHLY;COUNT=3;BYMONTHDAY=-1", "20240131T100000", []string{"20240131T100000", "20240229T100000", "20240331T100000"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.rule, time.UTC)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", test.rule, err)
+			}
+
+			dtstart, _ := time.ParseInLocation(rruleLayout, test.dtstart, time.UTC)
+			got := formatTimes(rule.Occurrences(dtstart, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)))
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("Occurrences()\n got  %v\n want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRRuleOccurrencesUntilAndCount(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	dtstart := time.Date(1997, 9, 2, 9, 0, 0, 0, newYork)
+	limit := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule string
+		want int
+	}{
+		// UNTIL is inclusive, 09:00 EDT is 13:00 UTC
+		{"UNTIL on an instance includes it", "FREQ=DAILY;UNTIL=19970905T130000Z", 4},
+		{"UNTIL before an instance excludes it", "FREQ=DAILY;UNTIL=19970905T125959Z", 3},
+		{"date UNTIL includes the whole day", "FREQ=DAILY;UNTIL=19970905", 4},
+		{"floating UNTIL is in the timezone of DTSTART", "FREQ=DAILY;UNTIL=19970905T090000", 4},
+		{"UNTIL before COUNT is reached", "FREQ=DAILY;COUNT=10;UNTIL=19970905T130000Z", 4},
+		{"COUNT before UNTIL is reached", "FREQ=DAILY;COUNT=2;UNTIL=19970905T130000Z", 2},
+		{"COUNT=1 is only DTSTART", "FREQ=DAILY;COUNT=1", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.rule, newYork)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", test.rule, err)
+			}
+
+			if got := rule.Occurrences(dtstart, limit); len(got) != test.want {
+				t.Errorf("got %d occurrences %v, want %d", len(got), formatTimes(got), test.want)
+			}
+		})
+	}
+}
+
+func TestRRuleOccurrencesKeepWallClockAcrossDST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	rule, err := ParseRRule("FREQ=DAILY;COUNT=3", berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Summer time starts on the 31st of March 2024
+	got := rule.Occurrences(time.Date(2024, 3, 30, 9, 0, 0, 0, berlin), time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+	want := []string{"2024-03-30T09:00:00+01:00", "2024-03-31T09:00:00+02:00", "2024-04-01T09:00:00+02:00"}
+
+	for i, occurrence := range got {
+		if i >= len(want) || occurrence.Format(time.RFC3339) != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d", len(got), len(want))
+	}
+	if hours := got[1].Sub(got[0]).Hours(); hours != 23 {
+		t.Errorf("got %v hours between instances across DST, want 23", hours)
+	}
+}
+
+func TestRRuleOccurrencesStopAtLimit(t *testing.T) {
+	rule, err := ParseRRule("FREQ=WEEKLY", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dtstart := time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)
+	got := rule.Occurrences(dtstart, dtstart.AddDate(0, 0, 21))
+	if len(got) != 4 {
+		t.Errorf("got %d occurrences, want 4", len(got))
+	}
+
+	// Rules which can never match still stop at the limit
+	rule, err = ParseRRule("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rule.Occurrences(dtstart, dtstart.AddDate(10, 0, 0)); len(got) != 1 {
+		t.Errorf("got %d occurrences, want only DTSTART", len(got))
+	}
+}
+
+func TestParseRRule(t *testing.T) {
+	rule, err := ParseRRule("FREQ=MONTHLY;INTERVAL=2;BYDAY=-2MO,1FR,TU;BYSETPOS=-1;WKST=SU;X-NAME=1", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rule.Freq != FrequencyMonthly || rule.Interval != 2 || rule.Wkst != time.Sunday {
+		t.Errorf("got FREQ %v, INTERVAL %d and WKST %v", rule.Freq, rule.Interval, rule.Wkst)
+	}
+	wantByDay := []WeekdayNum{{N: -2, Weekday: time.Monday}, {N: 1, Weekday: time.Friday}, {Weekday: time.Tuesday}}
+	if len(rule.ByDay) != len(wantByDay) {
+		t.Fatalf("got BYDAY %v, want %v", rule.ByDay, wantByDay)
+	}
+	for i := range wantByDay {
+		if rule.ByDay[i] != wantByDay[i] {
+			t.Errorf("got BYDAY %v, want %v", rule.ByDay, wantByDay)
+		}
+	}
+	if len(rule.BySetPos) != 1 || rule.BySetPos[0] != -1 {
+		t.Errorf("got BYSETPOS %v, want [-1]", rule.BySetPos)
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"COUNT=3", "has no FREQ"},
+		{"FREQ=FORTNIGHTLY", "unknown FREQ"},
+		{"FREQ=DAILY;INTERVAL=0", "invalid INTERVAL"},
+		{"FREQ=DAILY;COUNT=x", "invalid COUNT"},
+		{"FREQ=DAILY;UNTIL=tomorrow", "invalid UNTIL"},
+		{"FREQ=WEEKLY;BYDAY=XX", "invalid BYDAY"},
+		{"FREQ=MONTHLY;BYDAY=0MO", "invalid BYDAY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", "invalid BYMONTHDAY"},
+		{"FREQ=YEARLY;BYMONTH=13", "invalid BYMONTH"},
+		{"FREQ=YEARLY;BYWEEKNO=-54", "invalid BYWEEKNO"},
+		{"FREQ=WEEKLY;WKST=XX", "invalid WKST"},
+		{"FREQ=DAILY;COUNT", "invalid rule part"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.rule, func(t *testing.T) {
+			_, err := ParseRRule(test.rule, time.UTC)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("ParseRRule(%q) error = %v, want %q", test.rule, err, test.want)
+			}
+		})
+	}
+}
+
+// Skipping ahead to 'from' gives the same instances as expanding every
+// period since DTSTART
+func TestRRuleOccurrencesFrom(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	dtstart := time.Date(2020, 1, 31, 9, 30, 15, 0, berlin)
+	from := time.Date(2024, 3, 30, 12, 0, 0, 0, berlin)
+	limit := time.Date(2024, 11, 1, 0, 0, 0, 0, berlin)
+
+	for _, value := range []string{
+		"FREQ=YEARLY;BYMONTH=4,10;BYDAY=-1SU",
+		"FREQ=MONTHLY;INTERVAL=5;BYMONTHDAY=-1",
+		"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		"FREQ=WEEKLY;INTERVAL=3;BYDAY=SA,SU",
+		"FREQ=DAILY;INTERVAL=7",
+		"FREQ=HOURLY;INTERVAL=5",
+		"FREQ=MINUTELY;INTERVAL=90;BYHOUR=9,10",
+		"FREQ=DAILY;UNTIL=20240915T000000Z",
+	} {
+		t.Run(value, func(t *testing.T) {
+			rule, err := ParseRRule(value, berlin)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			for _, occurrence := range rule.Occurrences(dtstart, limit) {
+				if !occurrence.Before(from) {
+					want = append(want, occurrence.Format(time.RFC3339))
+				}
+			}
+
+			var got []string
+			for _, occurrence := range rule.occurrences(dtstart, from, limit, false) {
+				if !occurrence.Before(from) {
+					got = append(got, occurrence.Format(time.RFC3339))
+				}
+			}
+
+			if len(want) == 0 || strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("occurrences from %s\n got  %v\n want %v", from, got, want)
+			}
+		})
+	}
+}
+
+func TestRRuleOccurrencesLimits(t *testing.T) {
+	rule, err := ParseRRule("FREQ=SECONDLY;INTERVAL=10", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the periods of the last day (and one before it) are searched
+	dtstart := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	got := rule.occurrences(dtstart, from, from.AddDate(0, 0, 1), false)
+	if len(got) != 8642 || !got[1].Equal(from) {
+		t.Errorf("got %d occurrences from %s, want 8642 from %s", len(got), got[0], from.Add(-10*time.Second))
+	}
+
+	// The number of instances is capped
+	if got := rule.Occurrences(dtstart, dtstart.AddDate(1, 0, 0)); len(got) != maxRecurrences {
+		t.Errorf("got %d occurrences, want %d", len(got), maxRecurrences)
+	}
+
+	// As is the number of periods searched, when nothing matches
+	rule, err = ParseRRule("FREQ=SECONDLY;BYSECOND=60;COUNT=2", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rule.Occurrences(dtstart, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)); len(got) != 1 {
+		t.Errorf("got %d occurrences, want only DTSTART", len(got))
+	}
+}
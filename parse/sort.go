@@ -0,0 +1,117 @@
+package parse
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Field events are sorted by, descending when prefixed with '-' or
+// suffixed with ':desc' (e.g. '-duration' or 'duration:desc')
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// Fields events can be sorted by, and how two events compare on each
+var sortFields = map[string]func(a ICSEvent, b ICSEvent) int{
+	"start":       func(a, b ICSEvent) int { return a.Start.Compare(b.Start) },
+	"end":         func(a, b ICSEvent) int { return a.End.Compare(b.End) },
+	"duration":    func(a, b ICSEvent) int { return cmp.Compare(a.End.Sub(a.Start), b.End.Sub(b.Start)) },
+	"summary":     func(a, b ICSEvent) int { return compareText(a.Summary, b.Summary) },
+	"location":    func(a, b ICSEvent) int { return compareText(a.Location, b.Location) },
+	"calendar":    func(a, b ICSEvent) int { return compareText(a.Calendar, b.Calendar) },
+	"status":      func(a, b ICSEvent) int { return compareText(a.Status, b.Status) },
+	"organizer":   func(a, b ICSEvent) int { return compareText(a.Organizer, b.Organizer) },
+	"description": func(a, b ICSEvent) int { return compareText(a.Description, b.Description) },
+	"uid":         func(a, b ICSEvent) int { return strings.Compare(a.UID, b.UID) },
+}
+
+// Order used when no sort is chosen
+var DefaultSortKeys = []SortKey{{Field: "start"}}
+
+// Compared after the chosen keys, so events which are otherwise equal
+// (e.g. starting together) always come out in the same order
+var sortTieBreakers = []SortKey{{Field: "start"}, {Field: "end"}, {Field: "summary"}, {Field: "location"}, {Field: "uid"}}
+
+// Sorted names of the fields events can be sorted by
+func SortFields() []string {
+	fields := make([]string, 0, len(sortFields))
+	for field := range sortFields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// Parse a comma separated list of sort keys, e.g. 'start,-duration,summary'
+// or 'start,duration:desc,summary:asc'
+func ParseSortKeys(spec string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		field, direction, hasDirection := strings.Cut(item, ":")
+		field = strings.TrimSpace(field)
+		key := SortKey{Field: strings.TrimLeft(field, "+-"), Descending: strings.HasPrefix(field, "-")}
+
+		if hasDirection {
+			if key.Field != field {
+				return nil, fmt.Errorf("sort field '%s' has both a prefix and a direction, use one", item)
+			}
+			switch direction = strings.TrimSpace(direction); direction {
+			case "asc":
+			case "desc":
+				key.Descending = true
+			default:
+				return nil, fmt.Errorf("unknown sort direction '%s' in '%s' (use asc or desc)", direction, item)
+			}
+		}
+
+		if _, ok := sortFields[key.Field]; !ok {
+			return nil, fmt.Errorf("unknown sort field '%s' (available: %s)", key.Field, strings.Join(SortFields(), ", "))
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no sort fields in '%s'", spec)
+	}
+	return keys, nil
+}
+
+// Sort events in place by 'keys' (DefaultSortKeys when empty).
+//
+// The sort is stable, with ties broken by start, end, summary,
+// location and UID so the order does not depend on the source.
+func ICSEventsSort(events []ICSEvent, keys []SortKey) {
+	if len(keys) == 0 {
+		keys = DefaultSortKeys
+	}
+	keys = append(slices.Clip(keys), sortTieBreakers...)
+
+	slices.SortStableFunc(events, func(a, b ICSEvent) int {
+		for _, key := range keys {
+			c := sortFields[key.Field](a, b)
+			if key.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// Compare text ignoring case, then exactly so different texts never tie
+func compareText(a string, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
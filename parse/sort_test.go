@@ -0,0 +1,102 @@
+package parse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSortKeys(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []SortKey
+	}{
+		{"start", []SortKey{{Field: "start"}}},
+		{"-start", []SortKey{{Field: "start", Descending: true}}},
+		{"+start", []SortKey{{Field: "start"}}},
+		{"start:desc", []SortKey{{Field: "start", Descending: true}}},
+		{"start:asc", []SortKey{{Field: "start"}}},
+		{" Start : DESC ", []SortKey{{Field: "start", Descending: true}}},
+		{"start,-duration,summary", []SortKey{{Field: "start"}, {Field: "duration", Descending: true}, {Field: "summary"}}},
+		{"start,duration:desc,summary:asc", []SortKey{{Field: "start"}, {Field: "duration", Descending: true}, {Field: "summary"}}},
+		{"calendar,,start", []SortKey{{Field: "calendar"}, {Field: "start"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			got, err := ParseSortKeys(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSortKeysErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "no sort fields in ''"},
+		{" , ", "no sort fields in ' , '"},
+		{"priority", "unknown sort field 'priority' (available: " + strings.Join(SortFields(), ", ") + ")"},
+		{"start:down", "unknown sort direction 'down' in 'start:down' (use asc or desc)"},
+		{"-start:desc", "sort field '-start:desc' has both a prefix and a direction, use one"},
+		{"desc:start", "unknown sort direction 'start' in 'desc:start' (use asc or desc)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			_, err := ParseSortKeys(test.spec)
+			if err == nil || err.Error() != test.want {
+				t.Errorf("got error %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestICSEventsSort(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 9, 2, hour, 0, 0, 0, time.UTC) }
+	events := []ICSEvent{
+		{Summary: "b", Start: at(9), End: at(10), UID: "2"},
+		{Summary: "A", Start: at(11), End: at(14), UID: "3"},
+		{Summary: "c", Start: at(9), End: at(12), UID: "1"},
+		{Summary: "a", Start: at(9), End: at(10), UID: "4"},
+	}
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"start", "4 2 1 3"},
+		{"start:desc", "3 4 2 1"},
+		{"-start", "3 4 2 1"},
+		{"duration:desc,summary", "3 1 4 2"},
+		{"summary", "3 4 2 1"},
+		{"uid:desc", "4 3 2 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			keys, err := ParseSortKeys(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sorted := append([]ICSEvent(nil), events...)
+			ICSEventsSort(sorted, keys)
+
+			var uids []string
+			for _, event := range sorted {
+				uids = append(uids, event.UID)
+			}
+			if got := strings.Join(uids, " "); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Fields searched by text filters without a field
+var TextFilterFields = []string{"summary", "description", "location", "categories", "organizer", "attendees"}
+
+// Fields a text filter can be limited to
+var textFilterFieldAliases = map[string]string{
+	"summary":     "summary",
+	"title":       "summary",
+	"description": "description",
+	"location":    "location",
+	"categories":  "categories",
+	"category":    "categories",
+	"organizer":   "organizer",
+	"attendees":   "attendees",
+	"attendee":    "attendees",
+	"status":      "status",
+	"calendar":    "calendar",
+}
+
+// Matches text in the fields of an event
+type TextFilter struct {
+	// Fields searched, TextFilterFields when empty
+	Fields []string
+
+	// Substring matched ignoring case, unless Regex is set
+	Text  string
+	Regex *regexp.Regexp
+
+	// Matches the events which do not contain the text
+	Negate bool
+}
+
+var whereRegex = regexp.MustCompile(`^\s*([a-zA-Z-]+)\s*(!=|!~|=|~)(.*)$`)
+
+// Parse a text filter for all fields, either a substring ('Release')
+// or a regex between slashes ('/^v\d+/')
+func ParseTextFilter(value string) (TextFilter, error) {
+	if len(value) > 1 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		regex, err := regexp.Compile(value[1 : len(value)-1])
+		if err != nil {
+			return TextFilter{}, fmt.Errorf("invalid regex '%s': %v", value, err)
+		}
+		return TextFilter{Regex: regex}, nil
+	}
+
+	if value == "" {
+		return TextFilter{}, fmt.Errorf("empty text filter")
+	}
+	return TextFilter{Text: value}, nil
+}
+
+// Parse a text filter for one field:
+//
+//   - 'field=text' contains the text, ignoring case
+//   - 'field~regex' matches the regex
+//   - 'field!=text' and 'field!~regex' do not
+func ParseWhere(value string) (TextFilter, error) {
+	matched := whereRegex.FindStringSubmatch(value)
+	if matched == nil {
+		return TextFilter{}, fmt.Errorf("invalid filter '%s' (expected e.g. 'location~Room 4' or 'summary!=Private')", value)
+	}
+
+	field, ok := textFilterFieldAliases[strings.ToLower(matched[1])]
+	if !ok {
+		return TextFilter{}, fmt.Errorf("unknown field '%s' in filter '%s' (available: %s, status, calendar)", matched[1], value, strings.Join(TextFilterFields, ", "))
+	}
+
+	operator, text := matched[2], unquote(strings.TrimSpace(matched[3]))
+	filter := TextFilter{Fields: []string{field}, Negate: strings.HasPrefix(operator, "!")}
+
+	if strings.HasSuffix(operator, "~") {
+		regex, err := regexp.Compile(text)
+		if err != nil {
+			return TextFilter{}, fmt.Errorf("invalid regex in filter '%s': %v", value, err)
+		}
+		filter.Regex = regex
+		return filter, nil
+	}
+
+	if text == "" {
+		return TextFilter{}, fmt.Errorf("empty text in filter '%s'", value)
+	}
+	filter.Text = text
+	return filter, nil
+}
+
+// Checks if any of the filter's fields of an event contain the text
+// (or do not, when negated)
+func (f TextFilter) Matches(e ICSEvent) bool {
+	fields := f.Fields
+	if len(fields) == 0 {
+		fields = TextFilterFields
+	}
+
+	for _, field := range fields {
+		for _, value := range e.fieldValues(field) {
+			if f.matchesText(value) {
+				return !f.Negate
+			}
+		}
+	}
+	return f.Negate
+}
+
+func (f TextFilter) matchesText(value string) bool {
+	if f.Regex != nil {
+		return f.Regex.MatchString(value)
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(f.Text))
+}
+
+// Values of a field of an event, categories and attendees have one each
+// (attendees both their name and email)
+func (e ICSEvent) fieldValues(field string) []string {
+	switch field {
+	case "summary":
+		return []string{e.Summary}
+	case "description":
+		return []string{e.Description}
+	case "location":
+		return []string{e.Location}
+	case "categories":
+		return e.Categories
+	case "organizer":
+		return []string{e.Organizer}
+	case "attendees":
+		return append(slices.Clip(e.Attendees), e.AttendeeEmails...)
+	case "status":
+		return []string{e.Status}
+	case "calendar":
+		return []string{e.Calendar}
+	}
+	return nil
+}
+
+// Remove the quotes around a value, e.g. "location='Room 4'"
+func unquote(value string) string {
+	if len(value) > 1 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,71 @@
+package parse
+
+import (
+	"testing"
+	"time"
+)
+
+// Event with an attendee named in CN, and one known only by email
+func attendeeEvent(t *testing.T) ICSEvent {
+	t.Helper()
+
+	data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:planning\r\nSUMMARY:Planning\r\nDTSTART:20240902T090000Z\r\nDTEND:20240902T100000Z\r\n" +
+		"ATTENDEE;CN=Alice Smith:mailto:alice@example.com\r\nATTENDEE:mailto:bob@example.com\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	events, _, err := IcsToEventsWithOptions([]byte(data), ICSParseOptions{Timezone: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	return events[0]
+}
+
+func TestAttendeeNamesAndEmails(t *testing.T) {
+	event := attendeeEvent(t)
+
+	if got := event.Attendees; len(got) != 2 || got[0] != "Alice Smith" || got[1] != "bob@example.com" {
+		t.Errorf("got attendees %q, want [Alice Smith bob@example.com]", got)
+	}
+	if got := event.AttendeeEmails; len(got) != 2 || got[0] != "alice@example.com" || got[1] != "bob@example.com" {
+		t.Errorf("got attendee emails %q, want [alice@example.com bob@example.com]", got)
+	}
+}
+
+func TestWhereAttendee(t *testing.T) {
+	event := attendeeEvent(t)
+
+	tests := []struct {
+		where string
+		want  bool
+	}{
+		{"attendee=alice@example.com", true},
+		{"attendee=Alice", true},
+		{"attendees=ALICE@EXAMPLE", true},
+		{"attendee~^bob@", true},
+		{"attendee~^Alice Smith$", true},
+		{"attendee=carol@example.com", false},
+		{"attendee!=alice@example.com", false},
+		{"attendee!=carol", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.where, func(t *testing.T) {
+			filter, err := ParseWhere(test.where)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filter.Matches(event); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	filter, _ := ParseTextFilter("alice@example.com")
+	if !filter.Matches(event) {
+		t.Errorf("'--grep alice@example.com' does not match the attendee's email")
+	}
+}
@@ -0,0 +1,338 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// Transitions of VTIMEZONE definitions are generated up to this date
+var timezoneTransitionsUntil = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Resolves the timezones used by a calendar.
+//
+// TZIDs are looked up in the calendar's own VTIMEZONE definitions first,
+// then in the IANA database. Floating times (and unknown TZIDs) use the
+// calendar's X-WR-TIMEZONE, or the local timezone if there is none.
+type timezones struct {
+	defined  map[string]*time.Location
+	floating *time.Location
+}
+
+func newTimezones(calendar *ics.Calendar) *timezones {
+	tz := &timezones{
+		defined:  make(map[string]*time.Location),
+		floating: time.Local,
+	}
+
+	for _, vTimezone := range calendar.Timezones() {
+		tzid := vTimezone.GetProperty(ics.ComponentPropertyTzid)
+		if tzid == nil || tzid.Value == "" {
+			continue
+		}
+
+		loc, err := vTimezoneToLocation(tzid.Value, vTimezone)
+		if err == nil {
+			tz.defined[tzid.Value] = loc
+		}
+	}
+
+	for _, prop := range calendar.CalendarProperties {
+		if prop.IANAToken == string(ics.PropertyXWRTimezone) && prop.Value != "" {
+			if loc, ok := tz.lookup(prop.Value); ok {
+				tz.floating = loc
+			}
+		}
+	}
+
+	return tz
+}
+
+// Location for a TZID, falls back to the floating timezone if unknown
+func (tz *timezones) location(tzid string) *time.Location {
+	if loc, ok := tz.lookup(tzid); ok {
+		return loc
+	}
+	return tz.floating
+}
+
+func (tz *timezones) lookup(tzid string) (*time.Location, bool) {
+	tzid = strings.Trim(strings.TrimSpace(tzid), `"`)
+	if tzid == "" {
+		return nil, false
+	}
+
+	if loc, ok := tz.defined[tzid]; ok {
+		return loc, true
+	}
+
+	// Some producers prefix IANA names with a path,
+	// e.g. '/mozilla.org/20050126_1/Europe/London'
+	name := tzid
+	for name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc, true
+		}
+		_, name, _ = strings.Cut(name, "/")
+	}
+
+	return nil, false
+}
+
+// Parse a DATE or DATE-TIME value, returns true if the value is a DATE
+func (tz *timezones) parseDateTime(value string, params map[string][]string) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+
+	loc := tz.floating
+	if tzid, ok := params[string(ics.ParameterTzid)]; ok && len(tzid) > 0 {
+		loc = tz.location(tzid[0])
+	}
+
+	switch {
+	case len(value) == 8:
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.ParseInLocation("20060102T150405Z", value, time.UTC)
+		return t, false, err
+	default:
+		t, err := time.ParseInLocation("20060102T150405", value, loc)
+		return t, false, err
+	}
+}
+
+// Parse a comma separated list of DATE or DATE-TIME values (e.g. EXDATE)
+func (tz *timezones) parseDateTimeList(prop ics.IANAProperty) ([]time.Time, bool, error) {
+	var times []time.Time
+	isDate := false
+
+	for _, value := range strings.Split(prop.Value, ",") {
+		t, date, err := tz.parseDateTime(value, prop.ICalParameters)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %s '%s': %v", prop.IANAToken, value, err)
+		}
+		times = append(times, t)
+		isDate = date
+	}
+
+	return times, isDate, nil
+}
+
+// Parse a time property of a component, returns a zero time if missing
+func (tz *timezones) parseTimeProperty(component *ics.ComponentBase, property ics.ComponentProperty) (time.Time, bool, error) {
+	prop := component.GetProperty(property)
+	if prop == nil {
+		return time.Time{}, false, nil
+	}
+	return tz.parseDateTime(prop.Value, prop.ICalParameters)
+}
+
+type zoneType struct {
+	offset int
+	isDST  bool
+	name   string
+}
+
+type zoneTransition struct {
+	at   int64
+	zone zoneType
+}
+
+// Build a location from the STANDARD and DAYLIGHT observances of a VTIMEZONE
+func vTimezoneToLocation(tzid string, vTimezone *ics.VTimezone) (*time.Location, error) {
+	var transitions []zoneTransition
+	var initial *zoneTransition
+	var initialOffset int
+
+	for _, component := range vTimezone.Components {
+		var observance *ics.ComponentBase
+		isDST := false
+
+		switch c := component.(type) {
+		case *ics.Standard:
+			observance = &c.ComponentBase
+		case *ics.Daylight:
+			observance = &c.ComponentBase
+			isDST = true
+		default:
+			continue
+		}
+
+		offsetFrom, err := parseUTCOffset(observance.GetProperty(ics.ComponentProperty(ics.PropertyTzoffsetfrom)))
+		if err != nil {
+			return nil, err
+		}
+		offsetTo, err := parseUTCOffset(observance.GetProperty(ics.ComponentProperty(ics.PropertyTzoffsetto)))
+		if err != nil {
+			return nil, err
+		}
+
+		name := ""
+		if tzname := observance.GetProperty(ics.ComponentProperty(ics.PropertyTzname)); tzname != nil {
+			name = tzname.Value
+		}
+
+		// Onsets are wall clock times in the offset being left
+		dtstart := observance.GetProperty(ics.ComponentPropertyDtStart)
+		if dtstart == nil {
+			return nil, fmt.Errorf("timezone '%s' has an observance without DTSTART", tzid)
+		}
+		start, err := time.ParseInLocation("20060102T150405", dtstart.Value, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+
+		onsets := []time.Time{start}
+		for _, prop := range observance.Properties {
+			switch prop.IANAToken {
+			case string(ics.ComponentPropertyRrule):
+				rule, err := ParseRRule(prop.Value, time.UTC)
+				if err != nil {
+					return nil, err
+				}
+				onsets = append(onsets, rule.Occurrences(start, timezoneTransitionsUntil)...)
+			case string(ics.ComponentPropertyRdate):
+				for _, value := range strings.Split(prop.Value, ",") {
+					onset, err := time.ParseInLocation("20060102T150405", value, time.UTC)
+					if err != nil {
+						return nil, err
+					}
+					onsets = append(onsets, onset)
+				}
+			}
+		}
+
+		zone := zoneType{offset: offsetTo, isDST: isDST, name: name}
+		for _, onset := range onsets {
+			transition := zoneTransition{at: onset.Unix() - int64(offsetFrom), zone: zone}
+			transitions = append(transitions, transition)
+
+			if initial == nil || transition.at < initial.at {
+				initial = &transition
+				initialOffset = offsetFrom
+			}
+		}
+	}
+
+	if initial == nil {
+		return nil, fmt.Errorf("timezone '%s' has no observances", tzid)
+	}
+
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].at < transitions[j].at
+	})
+
+	// Zone in use before the first transition
+	before := zoneType{offset: initialOffset}
+	for _, transition := range transitions {
+		if transition.zone.offset == initialOffset {
+			before = transition.zone
+			break
+		}
+	}
+
+	return time.LoadLocationFromTZData(tzid, buildTZif(before, transitions))
+}
+
+// Parse a UTC offset property value (e.g. '+0100', '-053000')
+func parseUTCOffset(prop *ics.IANAProperty) (int, error) {
+	if prop == nil {
+		return 0, fmt.Errorf("missing UTC offset")
+	}
+
+	value := strings.TrimSpace(prop.Value)
+	if len(value) != 5 && len(value) != 7 || (value[0] != '+' && value[0] != '-') {
+		return 0, fmt.Errorf("invalid UTC offset '%s'", value)
+	}
+
+	offset := 0
+	units := []int{3600, 60, 1}
+	for i := 1; i < len(value); i += 2 {
+		n, err := strconv.Atoi(value[i : i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid UTC offset '%s'", value)
+		}
+		offset += n * units[i/2]
+	}
+
+	if value[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// Encode zone transitions in the (version 2) TZif format, which is
+// the only way to construct a time.Location with transitions
+func buildTZif(before zoneType, transitions []zoneTransition) []byte {
+	zones := []zoneType{before}
+	indexes := make([]byte, 0, len(transitions))
+	times := make([]int64, 0, len(transitions))
+
+	for _, transition := range transitions {
+		// Skip transitions with the same instant
+		if len(times) > 0 && times[len(times)-1] == transition.at {
+			continue
+		}
+
+		// The zone before the first transition is kept apart, as Go only
+		// uses the first zone for earlier times when no transition does
+		index := -1
+		for i := 1; i < len(zones); i++ {
+			if zones[i] == transition.zone {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			index = len(zones)
+			zones = append(zones, transition.zone)
+		}
+
+		times = append(times, transition.at)
+		indexes = append(indexes, byte(index))
+	}
+
+	var abbrev bytes.Buffer
+	abbrevIndex := make([]int, len(zones))
+	for i, zone := range zones {
+		abbrevIndex[i] = abbrev.Len()
+		abbrev.WriteString(zone.name)
+		abbrev.WriteByte(0)
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(timeCount int, zoneCount int, charCount int) {
+		buf.WriteString("TZif2")
+		buf.Write(make([]byte, 15))
+		for _, n := range []int{0, 0, 0, timeCount, zoneCount, charCount} {
+			binary.Write(&buf, binary.BigEndian, uint32(n))
+		}
+	}
+
+	// Empty 32-bit block, only the 64-bit data is read
+	writeHeader(0, 0, 0)
+
+	writeHeader(len(times), len(zones), abbrev.Len())
+	for _, t := range times {
+		binary.Write(&buf, binary.BigEndian, t)
+	}
+	buf.Write(indexes)
+	for i, zone := range zones {
+		binary.Write(&buf, binary.BigEndian, int32(zone.offset))
+		if zone.isDST {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		buf.WriteByte(byte(abbrevIndex[i]))
+	}
+	buf.Write(abbrev.Bytes())
+
+	return buf.Bytes()
+}
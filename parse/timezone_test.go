@@ -0,0 +1,206 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// VTIMEZONE of Europe/Berlin under a TZID unknown to the IANA database,
+// with the onsets given as rules
+const vTimezoneRRule = `BEGIN:VTIMEZONE
+TZID:Custom Central European
+BEGIN:DAYLIGHT
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+TZNAME:CEST
+DTSTART:19700329T020000
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU
+END:DAYLIGHT
+BEGIN:STANDARD
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+TZNAME:CET
+DTSTART:19701025T030000
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU
+END:STANDARD
+END:VTIMEZONE`
+
+// The same timezone from 2023 to 2025, with the onsets given as dates
+const vTimezoneRDate = `BEGIN:VTIMEZONE
+TZID:Custom Central European
+BEGIN:STANDARD
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+TZNAME:CET
+DTSTART:20231029T030000
+RDATE:20241027T030000,20251026T030000
+END:STANDARD
+BEGIN:DAYLIGHT
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+TZNAME:CEST
+DTSTART:20240331T020000
+RDATE:20250330T020000
+END:DAYLIGHT
+END:VTIMEZONE`
+
+func testTimezone(t *testing.T, vTimezone string) *time.Location {
+	t.Helper()
+
+	data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" + strings.ReplaceAll(vTimezone, "\n", "\r\n") + "\r\nEND:VCALENDAR\r\n"
+	calendar, err := ics.ParseCalendar(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timezones := calendar.Timezones()
+	if len(timezones) != 1 {
+		t.Fatalf("got %d timezones, want 1", len(timezones))
+	}
+
+	loc, err := vTimezoneToLocation("Custom Central European", timezones[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.String() != "Custom Central European" {
+		t.Errorf("got location %q, want the TZID", loc.String())
+	}
+	return loc
+}
+
+// Offsets and abbreviations of 'loc' at each instant match Europe/Berlin
+func assertSameZones(t *testing.T, loc *time.Location, instants []time.Time) {
+	t.Helper()
+
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	for _, instant := range instants {
+		gotName, gotOffset := instant.In(loc).Zone()
+		wantName, wantOffset := instant.In(berlin).Zone()
+		if gotName != wantName || gotOffset != wantOffset {
+			t.Errorf("at %s got %s (%+d), want %s (%+d)", instant.Format(time.RFC3339), gotName, gotOffset, wantName, wantOffset)
+		}
+	}
+}
+
+// Instants a second before and at each transition
+func aroundTransitions(transitions ...time.Time) []time.Time {
+	var instants []time.Time
+	for _, transition := range transitions {
+		instants = append(instants, transition.Add(-time.Second), transition, transition.Add(time.Hour))
+	}
+	return instants
+}
+
+func TestVTimezoneToLocationRRule(t *testing.T) {
+	loc := testTimezone(t, vTimezoneRRule)
+
+	instants := aroundTransitions(
+		time.Date(2023, 10, 29, 1, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC),
+		time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 30, 1, 0, 0, 0, time.UTC),
+		time.Date(2037, 3, 29, 1, 0, 0, 0, time.UTC),
+		time.Date(2037, 10, 25, 1, 0, 0, 0, time.UTC),
+	)
+
+	// Before the first transition, in 1970, Berlin had no summer time
+	instants = append(instants, time.Date(1969, 7, 1, 12, 0, 0, 0, time.UTC), time.Date(1970, 3, 29, 0, 59, 59, 0, time.UTC))
+
+	assertSameZones(t, loc, instants)
+
+	// Summer time in 1970 is only in the VTIMEZONE
+	name, offset := time.Date(1970, 7, 1, 12, 0, 0, 0, time.UTC).In(loc).Zone()
+	if name != "CEST" || offset != 2*60*60 {
+		t.Errorf("got %s (%+d) in July 1970, want CEST (+7200)", name, offset)
+	}
+}
+
+func TestVTimezoneToLocationRDate(t *testing.T) {
+	loc := testTimezone(t, vTimezoneRDate)
+
+	instants := aroundTransitions(
+		time.Date(2023, 10, 29, 1, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC),
+		time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 30, 1, 0, 0, 0, time.UTC),
+		time.Date(2025, 10, 26, 1, 0, 0, 0, time.UTC),
+	)
+
+	// Before the first transition (leaving summer time) is summer time
+	instants = append(instants, time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC))
+
+	assertSameZones(t, loc, instants)
+}
+
+func TestVTimezoneToLocationWallClock(t *testing.T) {
+	loc := testTimezone(t, vTimezoneRRule)
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	// Wall clock times in the custom timezone are the same instants as in Berlin
+	for _, wall := range []string{"20240331T013000", "20240331T033000", "20241027T013000", "20241027T033000", "20240715T090000", "20241215T090000"} {
+		got, _ := time.ParseInLocation(rruleLayout, wall, loc)
+		want, _ := time.ParseInLocation(rruleLayout, wall, berlin)
+		if !got.Equal(want) {
+			t.Errorf("%s is %s, want %s", wall, got.Format(time.RFC3339), want.Format(time.RFC3339))
+		}
+	}
+}
+
+func TestTimezonesResolveCustomTZID(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" + strings.ReplaceAll(vTimezoneRRule, "\n", "\r\n") + "\r\n" +
+		"BEGIN:VEVENT\r\nUID:custom\r\nSUMMARY:Custom\r\nDTSTART;TZID=Custom Central European:20240715T090000\r\nDTEND;TZID=Custom Central European:20240715T100000\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	events, _, err := IcsToEventsWithOptions([]byte(data), ICSParseOptions{Timezone: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if got := events[0].Start.Format(time.RFC3339); got != "2024-07-15T07:00:00Z" {
+		t.Errorf("got start %s, want 2024-07-15T07:00:00Z", got)
+	}
+}
+
+func TestVTimezoneToLocationErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		vTimezone string
+		want      string
+	}{
+		{
+			name:      "no observances",
+			vTimezone: "BEGIN:VTIMEZONE\nTZID:Custom Central European\nEND:VTIMEZONE",
+			want:      "has no observances",
+		},
+		{
+			name:      "invalid offset",
+			vTimezone: "BEGIN:VTIMEZONE\nTZID:Custom Central European\nBEGIN:STANDARD\nTZOFFSETFROM:+1\nTZOFFSETTO:+0100\nDTSTART:19701025T030000\nEND:STANDARD\nEND:VTIMEZONE",
+			want:      "invalid UTC offset",
+		},
+		{
+			name:      "no DTSTART",
+			vTimezone: "BEGIN:VTIMEZONE\nTZID:Custom Central European\nBEGIN:STANDARD\nTZOFFSETFROM:+0200\nTZOFFSETTO:+0100\nEND:STANDARD\nEND:VTIMEZONE",
+			want:      "without DTSTART",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" + strings.ReplaceAll(test.vTimezone, "\n", "\r\n") + "\r\nEND:VCALENDAR\r\n"
+			calendar, err := ics.ParseCalendar(strings.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = vTimezoneToLocation("Custom Central European", calendar.Timezones()[0])
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Table column, identified by key with a header label
+type Column struct {
+	Key string
+
+	// Header label, empty uses the localized default
+	Label string
+}
+
+type columnDefinition struct {
+	// Key in Document.HasEventValue, used to hide empty default columns
+	valueKey string
+
+	value func(f *Formatter, event parse.ICSEvent) string
+}
+
+var columnDefinitions = map[string]columnDefinition{
+	"date": {
+		valueKey: "start",
+		value:    (*Formatter).EventDate,
+	},
+	"time": {
+		valueKey: "start",
+		value:    (*Formatter).EventTime,
+	},
+	"start-date": {
+		valueKey: "start",
+		value: func(f *Formatter, event parse.ICSEvent) string {
+			return f.Date(event.FirstDay())
+		},
+	},
+	"end-date": {
+		valueKey: "end",
+		value: func(f *Formatter, event parse.ICSEvent) string {
+			return f.Date(event.LastDay())
+		},
+	},
+	"duration": {
+		valueKey: "end",
+		value:    (*Formatter).Duration,
+	},
+	"location": {
+		valueKey: "location",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.Location },
+	},
+	"summary": {
+		valueKey: "summary",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.Summary },
+	},
+	"description": {
+		valueKey: "description",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.Description },
+	},
+	"status": {
+		valueKey: "status",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.Status },
+	},
+	"organizer": {
+		valueKey: "organizer",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.Organizer },
+	},
+	"attendees": {
+		valueKey: "attendees",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return strings.Join(event.Attendees, ", ") },
+	},
+	"categories": {
+		valueKey: "categories",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return strings.Join(event.Categories, ", ") },
+	},
+	"url": {
+		valueKey: "url",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.URL },
+	},
+	"uid": {
+		valueKey: "uid",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.UID },
+	},
+	"calendar": {
+		valueKey: "calendar",
+		value:    func(f *Formatter, event parse.ICSEvent) string { return event.Calendar },
+	},
+}
+
+// Columns used when none are chosen, each is hidden if no event has a value
+var DefaultColumns = []string{"date", "time", "location", "summary", "description"}
+
+// Sorted keys of all available columns
+func ColumnKeys() []string {
+	keys := make([]string, 0, len(columnDefinitions))
+	for key := range columnDefinitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Parse a comma separated list of columns, each optionally
+// renamed with a colon, e.g. 'summary:What,date,time:When'.
+// Each column can only be given once.
+func ParseColumns(spec string) ([]Column, error) {
+	var columns []Column
+	for _, item := range strings.Split(spec, ",") {
+		key, label, _ := strings.Cut(strings.TrimSpace(item), ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+
+		if _, ok := columnDefinitions[key]; !ok {
+			return nil, fmt.Errorf("unknown column '%s' (available: %s)", key, strings.Join(ColumnKeys(), ", "))
+		}
+		if slices.ContainsFunc(columns, func(column Column) bool { return column.Key == key }) {
+			return nil, fmt.Errorf("column '%s' is given more than once", key)
+		}
+
+		columns = append(columns, Column{Key: key, Label: strings.TrimSpace(label)})
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return columns, nil
+}
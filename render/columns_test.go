@@ -0,0 +1,72 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []Column
+	}{
+		{"date", []Column{{Key: "date"}}},
+		{"summary:What,date,time:When", []Column{{Key: "summary", Label: "What"}, {Key: "date"}, {Key: "time", Label: "When"}}},
+		{" Summary : What , DATE ", []Column{{Key: "summary", Label: "What"}, {Key: "date"}}},
+		{"date,,time,", []Column{{Key: "date"}, {Key: "time"}}},
+		{"url:Link: more", []Column{{Key: "url", Label: "Link: more"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			got, err := ParseColumns(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseColumnsErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"priority", "unknown column 'priority' (available: " + strings.Join(ColumnKeys(), ", ") + ")"},
+		{"date,summary,Date:Day", "column 'date' is given more than once"},
+		{"", "no columns given"},
+		{" , ", "no columns given"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			_, err := ParseColumns(test.spec)
+			if err == nil || err.Error() != test.want {
+				t.Errorf("got error %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+// Separators are as wide as their header, and at least 3 dashes
+func TestTableRendererColumnWidths(t *testing.T) {
+	columns, err := ParseColumns("summary:At,duration:Größe,calendar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := (&TableRenderer{Columns: columns, ShowCalendar: true}).Render(testDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "| At | Größe | Calendar |\n" +
+		"| --- | ----- | -------- |\n"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("got header\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Heading per day, followed by a bullet list of that day's events
+//
+// Events spanning multiple days are listed under the day they start.
+type DaysRenderer struct{}
+
+func (r *DaysRenderer) Render(doc *Document) (string, error) {
+	var out strings.Builder
+	formatter := doc.formatter()
+
+	for i, group := range formatter.GroupByDay(doc.Events) {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "## %s\n\n", group.Title)
+
+		for _, event := range group.Events {
+			label := formatter.EventTime(event)
+			if event.IsMultiDay() {
+				label = fmt.Sprintf("%s (%s %s)", label, formatter.Locale.Label("until"), formatter.Date(event.LastDay()))
+			}
+
+			fmt.Fprintf(&out, "- **%s**", label)
+			writeEventDetails(&out, event)
+		}
+	}
+
+	return out.String(), nil
+}
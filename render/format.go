@@ -0,0 +1,231 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Formats dates, times and durations with localized names
+type Formatter struct {
+	Locale *Locale
+
+	// Go layouts
+	DateFormat string
+	TimeFormat string
+}
+
+// Create a formatter for a locale, empty formats use the locale defaults.
+//
+// Formats can be Go layouts ('02.01.2006', '3:04 PM') or
+// strftime-style ('%d.%m.%Y', '%I:%M %p').
+func NewFormatter(localeName string, dateFormat string, timeFormat string) (*Formatter, error) {
+	if localeName == "" {
+		localeName = DefaultLocale
+	}
+
+	locale, err := GetLocale(localeName)
+	if err != nil {
+		return nil, err
+	}
+
+	if dateFormat == "" {
+		dateFormat = locale.DateFormat
+	}
+	if timeFormat == "" {
+		timeFormat = locale.TimeFormat
+	}
+
+	formatter := &Formatter{Locale: locale}
+	if formatter.DateFormat, err = ToLayout(dateFormat); err != nil {
+		return nil, err
+	}
+	if formatter.TimeFormat, err = ToLayout(timeFormat); err != nil {
+		return nil, err
+	}
+
+	return formatter, nil
+}
+
+// Formatter used when a document does not set one
+func DefaultFormatter() *Formatter {
+	formatter, _ := NewFormatter(DefaultLocale, "", "")
+	return formatter
+}
+
+// Format a time using a Go layout, with localized weekday and month names
+func (f *Formatter) Format(t time.Time, layout string) string {
+	var out strings.Builder
+	segmentStart := 0
+
+	for i := 0; i < len(layout); {
+		name, length := f.localizedName(t, layout[i:])
+		if length == 0 {
+			i++
+			continue
+		}
+
+		out.WriteString(t.Format(layout[segmentStart:i]))
+		out.WriteString(name)
+		i += length
+		segmentStart = i
+	}
+
+	out.WriteString(t.Format(layout[segmentStart:]))
+	return out.String()
+}
+
+// Localized name for a weekday or month layout token at the start of 'layout'
+func (f *Formatter) localizedName(t time.Time, layout string) (string, int) {
+	switch {
+	case strings.HasPrefix(layout, "Monday"):
+		return f.Locale.Weekdays[t.Weekday()], len("Monday")
+	case strings.HasPrefix(layout, "Mon"):
+		return f.Locale.ShortWeekdays[t.Weekday()], len("Mon")
+	case strings.HasPrefix(layout, "January"):
+		return f.Locale.Months[t.Month()-1], len("January")
+	case strings.HasPrefix(layout, "Jan"):
+		return f.Locale.ShortMonths[t.Month()-1], len("Jan")
+	}
+	return "", 0
+}
+
+func (f *Formatter) Date(t time.Time) string {
+	return f.Format(t, f.DateFormat)
+}
+
+func (f *Formatter) Time(t time.Time) string {
+	return f.Format(t, f.TimeFormat)
+}
+
+// Date of an event, or its date range if it spans multiple days
+func (f *Formatter) EventDate(event parse.ICSEvent) string {
+	date := f.Date(event.Start)
+	if event.IsMultiDay() {
+		date = fmt.Sprintf("%s → %s", date, f.Date(event.LastDay()))
+	}
+	return date
+}
+
+// Time range of an event, or 'All day'
+func (f *Formatter) EventTime(event parse.ICSEvent) string {
+	if event.AllDay {
+		return f.Locale.Label("all-day")
+	}
+
+	// Space out ranges of times which contain spaces (e.g. '3:04 PM')
+	separator := "-"
+	if strings.Contains(f.TimeFormat, " ") {
+		separator = " - "
+	}
+	return f.Time(event.Start) + separator + f.Time(event.End)
+}
+
+// Length of an event, in days for all-day events
+func (f *Formatter) Duration(event parse.ICSEvent) string {
+	if event.AllDay {
+		if days := event.Days(); days != 1 {
+			return fmt.Sprintf("%d %s", days, f.Locale.Label("days"))
+		}
+		return "1 " + f.Locale.Label("day")
+	}
+
+	minutes := int(event.End.Sub(event.Start).Minutes())
+	if minutes < 0 {
+		minutes = 0
+	}
+
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
+
+func (f *Formatter) DayHeading(t time.Time) string {
+	return f.Format(t, f.Locale.DayHeadingFormat)
+}
+
+func (f *Formatter) WeekHeading(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%s %d, %d", f.Locale.Label("week"), week, year)
+}
+
+func (f *Formatter) MonthHeading(t time.Time) string {
+	return f.Format(t, "January 2006")
+}
+
+var strftimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'j': "002",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'p': "PM",
+	'P': "pm",
+	'A': "Monday",
+	'a': "Mon",
+	'B': "January",
+	'b': "Jan",
+	'h': "Jan",
+	'Z': "MST",
+	'z': "-0700",
+	'F': "2006-01-02",
+	'R': "15:04",
+	'T': "15:04:05",
+	'%': "%",
+}
+
+// Directives with the '-' (no padding) flag, e.g. '%-d'
+var strftimeUnpaddedDirectives = map[byte]string{
+	'm': "1",
+	'd': "2",
+	'I': "3",
+	'M': "4",
+	'S': "5",
+}
+
+// Convert a strftime-style format to a Go layout.
+//
+// Formats without a '%' are assumed to be Go layouts already.
+func ToLayout(format string) (string, error) {
+	if !strings.Contains(format, "%") {
+		return format, nil
+	}
+
+	var layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout.WriteByte(format[i])
+			continue
+		}
+
+		directives := strftimeDirectives
+		if i+1 < len(format) && format[i+1] == '-' {
+			directives = strftimeUnpaddedDirectives
+			i++
+		}
+		if i+1 >= len(format) {
+			return "", fmt.Errorf("format '%s' ends with an incomplete directive", format)
+		}
+
+		i++
+		value, ok := directives[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive '%%%c' in format '%s'", format[i], format)
+		}
+		layout.WriteString(value)
+	}
+
+	return layout.String(), nil
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"testing"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+func TestToLayout(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%Y-%m-%d", "2006-01-02"},
+		{"%d.%m.%y", "02.01.06"},
+		{"%-d/%-m/%Y", "2/1/2006"},
+		{"%e %b", "_2 Jan"},
+		{"%A, %B %j", "Monday, January 002"},
+		{"%a %h", "Mon Jan"},
+		{"%I:%M %p", "03:04 PM"},
+		{"%-I:%M%P", "3:04pm"},
+		{"%H:%M:%S %Z %z", "15:04:05 MST -0700"},
+		{"%F %R", "2006-01-02 15:04"},
+		{"%T", "15:04:05"},
+		{"100%%", "100%"},
+		{"02.01.2006", "02.01.2006"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			got, err := ToLayout(test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestToLayoutErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%Q", "unsupported directive '%Q' in format '%Q'"},
+		{"%-y", "unsupported directive '%y' in format '%-y'"},
+		{"%d.%", "format '%d.%' ends with an incomplete directive"},
+		{"%-", "format '%-' ends with an incomplete directive"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			_, err := ToLayout(test.format)
+			if err == nil || err.Error() != test.want {
+				t.Errorf("got error %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatterFormat(t *testing.T) {
+	german, err := NewFormatter("de-DE", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	english := DefaultFormatter()
+
+	// A Tuesday in March, which is 'März' in German
+	tuesday := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		formatter *Formatter
+		layout    string
+		want      string
+	}{
+		{german, "Monday, 2. January 2006", "Dienstag, 5. März 2024"},
+		{german, "Mon 2 Jan", "Di 5 Mär"},
+		{german, "Jan/January, Mon/Monday", "Mär/März, Di/Dienstag"},
+		{german, german.DateFormat, "05.03.2024"},
+		{german, german.TimeFormat, "14:30"},
+		{english, "Monday, 2 January 2006", "Tuesday, 5 March 2024"},
+		{english, "Mon 15:04", "Tue 14:30"},
+		{english, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.formatter.Locale.Name+" "+test.layout, func(t *testing.T) {
+			if got := test.formatter.Format(tuesday, test.layout); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	formatter, err := NewFormatter("en_US", "%-d %B %Y", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := parse.ICSEvent{
+		Start: time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC),
+		End:   time.Date(2024, 3, 5, 16, 0, 0, 0, time.UTC),
+	}
+	if got, want := formatter.EventDate(event), "5 March 2024"; got != want {
+		t.Errorf("got date %q, want %q", got, want)
+	}
+	if got, want := formatter.EventTime(event), "2:30 PM - 4:00 PM"; got != want {
+		t.Errorf("got time %q, want %q", got, want)
+	}
+
+	german, err := NewFormatter("de", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allDay := parse.ICSEvent{AllDay: true, Start: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)}
+	if got, want := german.EventDate(allDay)+", "+german.EventTime(allDay)+", "+german.Duration(allDay), "05.03.2024 → 07.03.2024, Ganztägig, 3 Tage"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := german.WeekHeading(allDay.Start), "Woche 10, 2024"; got != want {
+		t.Errorf("got week heading %q, want %q", got, want)
+	}
+
+	if _, err := NewFormatter("fr", "", ""); err == nil || err.Error() != "unknown locale 'fr' (available: de, en, en-US)" {
+		t.Errorf("got error %v, want unknown locale 'fr'", err)
+	}
+	if _, err := NewFormatter("en", "%Q", ""); err == nil {
+		t.Errorf("got no error for an unsupported date format")
+	}
+}
@@ -0,0 +1,38 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Bullet list with an item per event
+type ListRenderer struct{}
+
+func (r *ListRenderer) Render(doc *Document) (string, error) {
+	var out strings.Builder
+	formatter := doc.formatter()
+
+	for _, event := range doc.Events {
+		fmt.Fprintf(&out, "- **%s, %s**", formatter.EventDate(event), formatter.EventTime(event))
+		writeEventDetails(&out, event)
+	}
+
+	return out.String(), nil
+}
+
+// Summary, location and description (as a nested item) of a list item
+func writeEventDetails(out *strings.Builder, event parse.ICSEvent) {
+	if event.Summary != "" {
+		fmt.Fprintf(out, ": %s", event.Summary)
+	}
+	if event.Location != "" {
+		fmt.Fprintf(out, " (%s)", event.Location)
+	}
+	out.WriteString("\n")
+
+	if event.Description != "" {
+		fmt.Fprintf(out, "  - %s\n", event.Description)
+	}
+}
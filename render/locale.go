@@ -0,0 +1,160 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names, labels and default formats for a language/region
+type Locale struct {
+	Name string
+
+	Weekdays      [7]string // Sunday first, like time.Weekday
+	ShortWeekdays [7]string
+	Months        [12]string
+	ShortMonths   [12]string
+
+	// Default layouts (Go or strftime-style)
+	DateFormat       string
+	TimeFormat       string
+	DayHeadingFormat string
+
+	// Column labels (by column key) and other text
+	Labels map[string]string
+}
+
+// Locale used when none is chosen
+const DefaultLocale = "en"
+
+var englishWeekdays = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+var englishShortWeekdays = [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+var englishMonths = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+var englishShortMonths = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+var englishLabels = map[string]string{
+	"date":        "Date",
+	"time":        "Time",
+	"start-date":  "Start date",
+	"end-date":    "End date",
+	"duration":    "Duration",
+	"location":    "Location",
+	"summary":     "Event",
+	"description": "Description",
+	"status":      "Status",
+	"organizer":   "Organizer",
+	"attendees":   "Attendees",
+	"categories":  "Categories",
+	"url":         "URL",
+	"uid":         "UID",
+	"calendar":    "Calendar",
+	"all-day":     "All day",
+	"day":         "day",
+	"days":        "days",
+	"until":       "until",
+	"week":        "Week",
+}
+
+var locales = map[string]*Locale{
+	"en": {
+		Name:             "en",
+		Weekdays:         englishWeekdays,
+		ShortWeekdays:    englishShortWeekdays,
+		Months:           englishMonths,
+		ShortMonths:      englishShortMonths,
+		DateFormat:       "2006-01-02",
+		TimeFormat:       "15:04",
+		DayHeadingFormat: "Monday, 2 January 2006",
+		Labels:           englishLabels,
+	},
+	"en-US": {
+		Name:             "en-US",
+		Weekdays:         englishWeekdays,
+		ShortWeekdays:    englishShortWeekdays,
+		Months:           englishMonths,
+		ShortMonths:      englishShortMonths,
+		DateFormat:       "01/02/2006",
+		TimeFormat:       "3:04 PM",
+		DayHeadingFormat: "Monday, January 2, 2006",
+		Labels:           englishLabels,
+	},
+	"de": {
+		Name:             "de",
+		Weekdays:         [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		ShortWeekdays:    [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		Months:           [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths:      [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		DateFormat:       "02.01.2006",
+		TimeFormat:       "15:04",
+		DayHeadingFormat: "Monday, 2. January 2006",
+		Labels: map[string]string{
+			"date":        "Datum",
+			"time":        "Uhrzeit",
+			"start-date":  "Beginn",
+			"end-date":    "Ende",
+			"duration":    "Dauer",
+			"location":    "Ort",
+			"summary":     "Termin",
+			"description": "Beschreibung",
+			"status":      "Status",
+			"organizer":   "Organisator",
+			"attendees":   "Teilnehmer",
+			"categories":  "Kategorien",
+			"url":         "URL",
+			"uid":         "UID",
+			"calendar":    "Kalender",
+			"all-day":     "Ganztägig",
+			"day":         "Tag",
+			"days":        "Tage",
+			"until":       "bis",
+			"week":        "Woche",
+		},
+	},
+}
+
+// Aliases for region specific names
+var localeAliases = map[string]string{
+	"en-gb": "en",
+	"en-us": "en-US",
+	"us":    "en-US",
+	"de-de": "de",
+	"de-at": "de",
+	"de-ch": "de",
+}
+
+// Get a locale by name (e.g. 'en', 'en-US', 'de-DE')
+func GetLocale(name string) (*Locale, error) {
+	if locale, ok := locales[name]; ok {
+		return locale, nil
+	}
+
+	normalized := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	if alias, ok := localeAliases[normalized]; ok {
+		return locales[alias], nil
+	}
+	for key, locale := range locales {
+		if strings.ToLower(key) == normalized {
+			return locale, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown locale '%s' (available: %s)", name, strings.Join(LocaleNames(), ", "))
+}
+
+// Sorted names of all built-in locales
+func LocaleNames() []string {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Localized label, falls back to English
+func (l *Locale) Label(key string) string {
+	if label, ok := l.Labels[key]; ok {
+		return label
+	}
+	return englishLabels[key]
+}
@@ -0,0 +1,104 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Format used when none is chosen
+const DefaultFormat = "table"
+
+// Everything a renderer needs to output a calendar.
+//
+// This is also the data passed to user templates (see TemplateRenderer).
+type Document struct {
+	// Events after filtering, in the order selected by --sort (start time by default)
+	Events []parse.ICSEvent
+
+	// Which values are present in at least one event (from parse.IcsToEvents)
+	HasEventValue map[string]bool
+
+	// Name and description of the calendar (may be empty)
+	CalendarName        string
+	CalendarDescription string
+
+	// Filter window, either may be a zero time when not set
+	Start time.Time
+	End   time.Time
+
+	// When the document was rendered
+	GeneratedAt time.Time
+
+	// Date, time and label formatting, nil uses DefaultFormatter
+	Formatter *Formatter
+}
+
+func (doc *Document) formatter() *Formatter {
+	if doc.Formatter == nil {
+		return DefaultFormatter()
+	}
+	return doc.Formatter
+}
+
+// Converts a document into markdown
+type Renderer interface {
+	Render(doc *Document) (string, error)
+}
+
+// Adapter to use an ordinary function as a Renderer
+type RendererFunc func(doc *Document) (string, error)
+
+func (f RendererFunc) Render(doc *Document) (string, error) {
+	return f(doc)
+}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{
+		"table": &TableRenderer{},
+		"list":  &ListRenderer{},
+		"days":  &DaysRenderer{},
+	}
+)
+
+// Register a renderer, making it available as a format.
+//
+// Registering an existing name replaces the previous renderer.
+func Register(name string, renderer Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+
+	if renderer == nil {
+		panic("render: Register renderer is nil")
+	}
+	renderers[name] = renderer
+}
+
+// Get the renderer registered for a format
+func Get(name string) (Renderer, error) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	renderer, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format '%s'", name)
+	}
+	return renderer, nil
+}
+
+// Sorted names of all registered formats
+func Names() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Document with a timed event, a multi-day all-day event and an
+// event with only a summary
+func testDocument() *Document {
+	return &Document{
+		Events: []parse.ICSEvent{
+			{
+				Summary:     "Standup",
+				Location:    "Room 4",
+				Description: "Daily sync",
+				Calendar:    "Work",
+				Start:       time.Date(2024, 9, 2, 9, 0, 0, 0, time.UTC),
+				End:         time.Date(2024, 9, 2, 9, 15, 0, 0, time.UTC),
+			},
+			{
+				Summary:  "Offsite",
+				Calendar: "Work",
+				AllDay:   true,
+				Start:    time.Date(2024, 9, 3, 0, 0, 0, 0, time.UTC),
+				End:      time.Date(2024, 9, 5, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				Summary:  "Dentist",
+				Calendar: "Home",
+				Start:    time.Date(2024, 9, 3, 14, 0, 0, 0, time.UTC),
+				End:      time.Date(2024, 9, 3, 15, 30, 0, 0, time.UTC),
+			},
+		},
+		HasEventValue: map[string]bool{"start": true, "end": true, "summary": true, "location": true, "description": true},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"table", "list", "days"} {
+		if _, err := Get(name); err != nil {
+			t.Errorf("Get(%q): %v", name, err)
+		}
+	}
+
+	if _, err := Get("csv"); err == nil || err.Error() != "unknown format 'csv'" {
+		t.Errorf("got error %v, want unknown format 'csv'", err)
+	}
+
+	Register("count", RendererFunc(func(doc *Document) (string, error) {
+		return fmt.Sprintf("%d events\n", len(doc.Events)), nil
+	}))
+	renderer, err := Get("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := renderer.Render(testDocument()); got != "3 events\n" {
+		t.Errorf("got %q from a registered renderer, want %q", got, "3 events\n")
+	}
+
+	if names := Names(); !slices.IsSorted(names) || !slices.Contains(names, "count") || !slices.Contains(names, DefaultFormat) {
+		t.Errorf("got names %v, want sorted names including 'count' and '%s'", names, DefaultFormat)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer Renderer
+		want     string
+	}{
+		{
+			"table",
+			&TableRenderer{},
+			"| Date | Time | Location | Event | Description |\n" +
+				"| ---- | ---- | -------- | ----- | ----------- |\n" +
+				"| 2024-09-02 | 09:00-09:15 | Room 4 | Standup | Daily sync |\n" +
+				"| 2024-09-03 → 2024-09-04 | All day |  | Offsite |  |\n" +
+				"| 2024-09-03 | 14:00-15:30 |  | Dentist |  |\n",
+		},
+		{
+			"table with calendars",
+			&TableRenderer{Columns: []Column{{Key: "summary"}, {Key: "duration", Label: "How long"}}, ShowCalendar: true},
+			"| Calendar | Event | How long |\n" +
+				"| -------- | ----- | -------- |\n" +
+				"| Work | Standup | 15m |\n" +
+				"| Work | Offsite | 2 days |\n" +
+				"| Home | Dentist | 1h30m |\n",
+		},
+		{
+			"list",
+			&ListRenderer{},
+			"- **2024-09-02, 09:00-09:15**: Standup (Room 4)\n" +
+				"  - Daily sync\n" +
+				"- **2024-09-03 → 2024-09-04, All day**: Offsite\n" +
+				"- **2024-09-03, 14:00-15:30**: Dentist\n",
+		},
+		{
+			"days",
+			&DaysRenderer{},
+			"## Monday, 2 September 2024\n\n" +
+				"- **09:00-09:15**: Standup (Room 4)\n" +
+				"  - Daily sync\n\n" +
+				"## Tuesday, 3 September 2024\n\n" +
+				"- **All day (until 2024-09-04)**: Offsite\n" +
+				"- **14:00-15:30**: Dentist\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.renderer.Render(testDocument())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Render()\n got\n%s\n want\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// Default columns without a value in any event are hidden
+func TestTableRendererHidesEmptyColumns(t *testing.T) {
+	doc := testDocument()
+	doc.HasEventValue = map[string]bool{"start": true, "end": true, "summary": true}
+
+	got, err := (&TableRenderer{}).Render(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "| Date | Time | Event |\n"; got[:len(want)] != want {
+		t.Errorf("got header %q, want %q", got[:len(want)], want)
+	}
+
+	if _, err := (&TableRenderer{Columns: []Column{{Key: "priority"}}}).Render(doc); err == nil || err.Error() != "unknown column 'priority'" {
+		t.Errorf("got error %v, want unknown column 'priority'", err)
+	}
+}
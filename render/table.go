@@ -0,0 +1,61 @@
+package render
+
+import (
+	"fmt"
+
+	"hmerritt/go-ics-to-markdown/parse"
+
+	"github.com/samber/lo"
+)
+
+// GFM table with a row per event (default format)
+type TableRenderer struct {
+	// Columns in order, with their header labels.
+	//
+	// When empty, DefaultColumns are used and any column
+	// without a value in at least one event is hidden.
+	Columns []Column
+
+	// Add a column with the calendar of each event (see parse.MergeCalendars)
+	ShowCalendar bool
+}
+
+func (r *TableRenderer) Render(doc *Document) (string, error) {
+	formatter := doc.formatter()
+
+	columns := r.Columns
+	if len(columns) == 0 {
+		for _, key := range DefaultColumns {
+			definition := columnDefinitions[key]
+			if doc.HasEventValue[definition.valueKey] {
+				columns = append(columns, Column{Key: key})
+			}
+		}
+	}
+
+	if r.ShowCalendar && !lo.ContainsBy(columns, func(column Column) bool { return column.Key == "calendar" }) {
+		columns = append([]Column{{Key: "calendar"}}, columns...)
+	}
+
+	var header []string
+	for _, column := range columns {
+		if _, ok := columnDefinitions[column.Key]; !ok {
+			return "", fmt.Errorf("unknown column '%s'", column.Key)
+		}
+
+		label := column.Label
+		if label == "" {
+			label = formatter.Locale.Label(column.Key)
+		}
+		header = append(header, label)
+	}
+
+	rows := make([][]string, len(doc.Events))
+	for i, event := range doc.Events {
+		for _, column := range columns {
+			rows[i] = append(rows[i], columnDefinitions[column.Key].value(formatter, event))
+		}
+	}
+
+	return parse.MarkdownTable(header, rows), nil
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Renders a user-supplied text/template.
+//
+// Templates are executed with a *Document, e.g.
+//
+//	# {{ .CalendarName }}
+//	{{ range groupByDay .Events }}
+//	## {{ .Title }}
+//	{{ range .Events }}- {{ eventTime . }} {{ escape .Summary }} ({{ duration . }})
+//	{{ end }}{{ end }}
+//
+// See TemplateFuncs for the helper functions available.
+type TemplateRenderer struct {
+	Template *template.Template
+}
+
+// Parse a template file into a TemplateRenderer
+func NewTemplateRenderer(path string) (*TemplateRenderer, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := template.New(filepath.Base(path)).Funcs(TemplateFuncs(DefaultFormatter())).Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateRenderer{Template: tpl}, nil
+}
+
+func (r *TemplateRenderer) Render(doc *Document) (string, error) {
+	// Rebind the helpers to the document's formatter
+	tpl, err := r.Template.Clone()
+	if err != nil {
+		return "", err
+	}
+	tpl.Funcs(TemplateFuncs(doc.formatter()))
+
+	var out strings.Builder
+	if err := tpl.Execute(&out, doc); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Events grouped by day, week or month
+type EventGroup struct {
+	// Heading for the group, e.g. 'Monday, 2 January 2006', 'Week 1, 2006', 'January 2006'
+	Title string
+
+	// Start of the day, week (Monday) or month
+	Start time.Time
+
+	Events []parse.ICSEvent
+}
+
+// Helper functions available in templates, formatted with 'f':
+//
+//	date "2006-01-02" .Start     format a time using a Go or strftime layout
+//	eventDate .                  event date, or date range for multi-day events
+//	eventTime .                  event time range, or 'All day'
+//	duration .                   event length, e.g. '1h30m' or '2 days'
+//	escape .Summary              escape markdown special characters
+//	groupByDay .Events           []EventGroup, one per day
+//	groupByWeek .Events          []EventGroup, one per ISO week
+//	groupByMonth .Events         []EventGroup, one per month
+//	lower, upper, trim           string helpers
+func TemplateFuncs(f *Formatter) template.FuncMap {
+	return template.FuncMap{
+		"date": func(layout string, t time.Time) (string, error) {
+			layout, err := ToLayout(layout)
+			if err != nil {
+				return "", err
+			}
+			return f.Format(t, layout), nil
+		},
+		"eventDate":    f.EventDate,
+		"eventTime":    f.EventTime,
+		"duration":     f.Duration,
+		"escape":       EscapeMarkdown,
+		"groupByDay":   f.GroupByDay,
+		"groupByWeek":  f.GroupByWeek,
+		"groupByMonth": f.GroupByMonth,
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"trim":         strings.TrimSpace,
+	}
+}
+
+var markdownSpecialChars = regexp.MustCompile("([\\\\`*_\\[\\]|<>~])")
+
+// Escape characters which have a meaning in markdown
+func EscapeMarkdown(text string) string {
+	return markdownSpecialChars.ReplaceAllString(text, `\$1`)
+}
+
+func (f *Formatter) GroupByDay(events []parse.ICSEvent) []EventGroup {
+	return groupEvents(events, func(t time.Time) (time.Time, string) {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day, f.DayHeading(day)
+	})
+}
+
+func (f *Formatter) GroupByWeek(events []parse.ICSEvent) []EventGroup {
+	return groupEvents(events, func(t time.Time) (time.Time, string) {
+		offset := (int(t.Weekday()) + 6) % 7
+		monday := time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+		return monday, f.WeekHeading(t)
+	})
+}
+
+func (f *Formatter) GroupByMonth(events []parse.ICSEvent) []EventGroup {
+	return groupEvents(events, func(t time.Time) (time.Time, string) {
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return month, f.MonthHeading(month)
+	})
+}
+
+// Group (sorted) events by the period their start falls in
+func groupEvents(events []parse.ICSEvent, period func(t time.Time) (time.Time, string)) []EventGroup {
+	var groups []EventGroup
+	for _, event := range events {
+		start, title := period(event.Start)
+		if len(groups) == 0 || !groups[len(groups)-1].Start.Equal(start) {
+			groups = append(groups, EventGroup{Title: title, Start: start})
+		}
+		groups[len(groups)-1].Events = append(groups[len(groups)-1].Events, event)
+	}
+	return groups
+}
@@ -0,0 +1,166 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"hmerritt/go-ics-to-markdown/parse"
+)
+
+// Template renderer for 'text', written to a temporary file
+func testTemplate(t *testing.T, text string) *TemplateRenderer {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "calendar.tmpl")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	renderer, err := NewTemplateRenderer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return renderer
+}
+
+func TestTemplateRenderer(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			"fields",
+			"# {{ .CalendarName }}\n{{ range .Events }}- {{ .Summary }} ({{ .Calendar }})\n{{ end }}",
+			"# Team\n- Standup (Work)\n- Offsite (Work)\n- Dentist (Home)\n",
+		},
+		{
+			"event helpers",
+			"{{ range .Events }}{{ eventDate . }} | {{ eventTime . }} | {{ duration . }}\n{{ end }}",
+			"2024-09-02 | 09:00-09:15 | 15m\n2024-09-03 → 2024-09-04 | All day | 2 days\n2024-09-03 | 14:00-15:30 | 1h30m\n",
+		},
+		{
+			"date layouts",
+			`{{ with index .Events 0 }}{{ date "Mon 2 Jan" .Start }}, {{ date "%A %d.%m.%Y" .Start }}{{ end }}`,
+			"Mon 2 Sep, Monday 02.09.2024",
+		},
+		{
+			"group by day",
+			"{{ range groupByDay .Events }}{{ .Title }}: {{ len .Events }}\n{{ end }}",
+			"Monday, 2 September 2024: 1\nTuesday, 3 September 2024: 2\n",
+		},
+		{
+			"group by week and month",
+			"{{ range groupByWeek .Events }}{{ .Title }}: {{ len .Events }}\n{{ end }}{{ range groupByMonth .Events }}{{ .Title }}\n{{ end }}",
+			"Week 36, 2024: 3\nSeptember 2024\n",
+		},
+		{
+			"string helpers",
+			`{{ upper "a" }}{{ lower "B" }}{{ trim "  c  " }} {{ escape "*bold* [link]|x_y" }}`,
+			`Abc \*bold\* \[link\]\|x\_y`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := testDocument()
+			doc.CalendarName = "Team"
+
+			got, err := testTemplate(t, test.template).Render(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Render()\n got\n%s\n want\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// Helpers use the formatter of the document being rendered
+func TestTemplateRendererFormatter(t *testing.T) {
+	formatter, err := NewFormatter("de", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renderer := testTemplate(t, "{{ range groupByDay .Events }}{{ .Title }}\n{{ range .Events }}- {{ eventTime . }}\n{{ end }}{{ end }}")
+
+	doc := testDocument()
+	doc.Formatter = formatter
+	got, err := renderer.Render(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Montag, 2. September 2024\n- 09:00-09:15\nDienstag, 3. September 2024\n- Ganztägig\n- 14:00-15:30\n"
+	if got != want {
+		t.Errorf("Render()\n got\n%s\n want\n%s", got, want)
+	}
+
+	// The renderer can be used again with another formatter
+	got, err = renderer.Render(testDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "Monday, 2 September 2024\n") {
+		t.Errorf("got %q after rendering in German, want English", got)
+	}
+}
+
+func TestTemplateRendererErrors(t *testing.T) {
+	if _, err := NewTemplateRenderer(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Errorf("got no error for a missing template")
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.tmpl")
+	if err := os.WriteFile(path, []byte("{{ range .Events }}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTemplateRenderer(path); err == nil {
+		t.Errorf("got no error for a template which does not parse")
+	}
+
+	for _, text := range []string{
+		"{{ .Missing }}",
+		`{{ date "%Q" .GeneratedAt }}`,
+	} {
+		if _, err := testTemplate(t, text).Render(testDocument()); err == nil {
+			t.Errorf("got no error executing %q", text)
+		}
+	}
+}
+
+func TestGroupEvents(t *testing.T) {
+	at := func(day int, hour int) parse.ICSEvent {
+		return parse.ICSEvent{Start: time.Date(2024, 9, day, hour, 0, 0, 0, time.UTC)}
+	}
+	events := []parse.ICSEvent{at(1, 9), at(2, 9), at(2, 18), at(8, 9), at(9, 9), at(30, 23)}
+	formatter := DefaultFormatter()
+
+	tests := []struct {
+		name   string
+		groups []EventGroup
+		want   string
+	}{
+		{"day", formatter.GroupByDay(events), "2024-09-01:1 2024-09-02:2 2024-09-08:1 2024-09-09:1 2024-09-30:1"},
+		{"week", formatter.GroupByWeek(events), "2024-08-26:1 2024-09-02:3 2024-09-09:1 2024-09-30:1"},
+		{"month", formatter.GroupByMonth(events), "2024-09-01:6"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, group := range test.groups {
+				got = append(got, fmt.Sprintf("%s:%d", group.Start.Format(time.DateOnly), len(group.Events)))
+			}
+			if strings.Join(got, " ") != test.want {
+				t.Errorf("got %s, want %s", strings.Join(got, " "), test.want)
+			}
+		})
+	}
+}
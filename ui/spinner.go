@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"os"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -10,13 +11,14 @@ type Spin struct {
 	Spinner *spinner.Spinner
 }
 
+// Spinners write to stderr, stdout may be used for markdown output
 var Spinner = &Spin{
-	spinner.New(spinner.CharSets[14], 80*time.Millisecond),
+	spinner.New(spinner.CharSets[14], 80*time.Millisecond, spinner.WithWriterFile(os.Stderr)),
 }
 
 func GetSpinner() *Spin {
 	return &Spin{
-		spinner.New(spinner.CharSets[14], 80*time.Millisecond),
+		spinner.New(spinner.CharSets[14], 80*time.Millisecond, spinner.WithWriterFile(os.Stderr)),
 	}
 }
 
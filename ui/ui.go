@@ -30,6 +30,13 @@ func GetUi() *Ui {
 	}
 }
 
+// Send regular output to stderr, keeping stdout free for data
+func (u *Ui) OutputToStderr() {
+	if basicUi, ok := u.Ui.(*cli.BasicUi); ok {
+		basicUi.Writer = os.Stderr
+	}
+}
+
 // Outputs green text
 func (u *Ui) Success(message string) {
 	u.Ui.Output(u.Colorize(message, cli.UiColorGreen))
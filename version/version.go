@@ -3,6 +3,7 @@ package version
 import (
 	"bytes"
 	"fmt"
+	"os"
 )
 
 // VersionInfo
@@ -103,7 +104,8 @@ func PrintTitle() {
 	// Get full version string
 	versionString := versionStruct.FullVersionNumber(isDev)
 
-	fmt.Println(versionString)
-	fmt.Println("(c) MerrittCorp. All rights reserved.")
-	fmt.Println()
+	// Printed to stderr, stdout may be used for markdown output
+	fmt.Fprintln(os.Stderr, versionString)
+	fmt.Fprintln(os.Stderr, "(c) MerrittCorp. All rights reserved.")
+	fmt.Fprintln(os.Stderr)
 }